@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/config"
+	"github.com/azvaliev/cmd/internal/pkg/daemon"
+)
+
+const daemonUsage = `Keep a llama-server running in the background so cmd starts instantly.
+
+Usage:
+  cmd daemon start [--model <profile>] [--idle-timeout <duration>]
+  cmd daemon stop
+  cmd daemon status
+
+The daemon exits after going unused for the idle timeout (default %s,
+or daemon.idle_timeout in the config file). Invocations using a different
+model profile than the daemon's spawn their own server as usual.
+
+Flags:
+`
+
+var daemonSubcommands = []string{"start", "stop", "status", "run"}
+
+// isDaemonCommand reports whether args invoke `cmd daemon <subcommand>`,
+// so a query that merely starts with "daemon" is still treated as a query
+func isDaemonCommand(args []string) bool {
+	return len(args) >= 2 && args[0] == "daemon" && slices.Contains(daemonSubcommands, args[1])
+}
+
+func runDaemonCommand(args []string, output io.Writer) int {
+	subcommand := args[0]
+
+	var model string
+	var idleTimeout time.Duration
+
+	flags := flag.NewFlagSet("cmd daemon "+subcommand, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&model, "model", "", "model profile to serve")
+	flags.DurationVar(&idleTimeout, "idle-timeout", 0, "shut down after going unused this long")
+	flags.Usage = func() {
+		fmt.Fprintf(output, daemonUsage, daemon.DEFAULT_IDLE_TIMEOUT)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	switch subcommand {
+	case "start":
+		return daemonStart(model, idleTimeout)
+	case "stop":
+		return daemonStop()
+	case "status":
+		return daemonStatus()
+	case "run":
+		return daemonRun(model, idleTimeout)
+	}
+
+	return 2
+}
+
+func daemonStart(model string, idleTimeout time.Duration) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// validate up front, errors from the detached process only end up in its log
+	modelConfig, err := cfg.Model(model)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if modelConfig.Backend != "" && modelConfig.Backend != ai.BACKEND_LLAMA_SERVER {
+		fmt.Fprintf(os.Stderr, "the daemon only serves %s profiles, this one uses %s\n", ai.BACKEND_LLAMA_SERVER, modelConfig.Backend)
+		return 1
+	}
+
+	if idleTimeout == 0 {
+		idleTimeout = cfg.Daemon.IdleTimeout
+	}
+	if idleTimeout == 0 {
+		idleTimeout = daemon.DEFAULT_IDLE_TIMEOUT
+	}
+
+	fmt.Println("Starting daemon...")
+	state, err := daemon.Start(model, idleTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	printDaemonState(state)
+	return 0
+}
+
+func daemonStop() int {
+	state, err := daemon.Stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if state == nil {
+		fmt.Println("Daemon is not running")
+	} else {
+		fmt.Printf("Stopped daemon (pid %d)\n", state.PID)
+	}
+	return 0
+}
+
+func daemonStatus() int {
+	state, err := daemon.ReadState()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if state == nil {
+		fmt.Println("Daemon is not running")
+		// non-zero so scripts can check `cmd daemon status`
+		return 3
+	}
+
+	printDaemonState(state)
+	return 0
+}
+
+// daemonRun is the detached process started by `cmd daemon start`
+func daemonRun(model string, idleTimeout time.Duration) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	modelConfig, err := cfg.Model(model)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if idleTimeout == 0 {
+		idleTimeout = daemon.DEFAULT_IDLE_TIMEOUT
+	}
+
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+
+	if err := daemon.Run(model, modelConfig, cfg.LlamaServer, idleTimeout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printDaemonState(state *daemon.State) {
+	fmt.Printf("Daemon running (pid %d)\n", state.PID)
+	fmt.Printf("  model:        %s (%s)\n", state.Profile, state.Model.Name)
+	fmt.Printf("  port:         %d\n", state.Port)
+	fmt.Printf("  up:           %s\n", time.Since(state.StartedAt).Round(time.Second))
+	fmt.Printf("  idle:         %s\n", time.Since(state.LastUsed).Round(time.Second))
+	fmt.Printf("  idle timeout: %s\n", state.IdleTimeout)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/azvaliev/cmd/internal/pkg/history"
+)
+
+// Overridden at build time with -ldflags="-X main.version=..."
+var version = ""
+
+type options struct {
+	model   string
+	print   bool
+	json    bool
+	noRun   bool
+	help    bool
+	version bool
+
+	// positional args joined with spaces, empty for interactive mode
+	prompt string
+
+	// picked from `cmd history`, opened at the confirm screen or with rerun, run straight away
+	entry *history.Entry
+	rerun bool
+}
+
+const usage = `cmd translates natural language into terminal commands.
+
+Usage:
+  cmd [flags] [query...]
+  cmd [flags] -- [query...]
+  echo "query" | cmd --print
+  cmd history [flags] [--] [text...]
+  cmd init zsh|bash|fish
+  cmd daemon start|stop|status
+
+Examples:
+  cmd
+  cmd find all files over 100MB
+  cmd --print -- list files modified in the last --day
+  cmd --json "compress this folder"
+
+Flags:
+`
+
+func parseFlags(args []string, output io.Writer) (options, error) {
+	var opts options
+
+	flags := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&opts.model, "model", "", "model to generate with")
+	flags.BoolVar(&opts.print, "print", false, "print the generated command to stdout without the interactive UI")
+	flags.BoolVar(&opts.json, "json", false, "print the prompt, command, explanation, model, and latency as JSON (implies --print)")
+	flags.BoolVar(&opts.noRun, "no-run", false, "print the accepted command instead of running it")
+	flags.BoolVar(&opts.help, "help", false, "show this help")
+	flags.BoolVar(&opts.version, "version", false, "show the version")
+	flags.Usage = func() {
+		fmt.Fprint(output, usage)
+		flags.PrintDefaults()
+	}
+
+	// flag stops parsing at the first positional arg or "--", so anything
+	// after the query starts (e.g. "find files -size") is kept as part of it
+	if err := flags.Parse(args); err != nil {
+		return options{}, err
+	}
+
+	if opts.help {
+		flags.Usage()
+		return options{}, flag.ErrHelp
+	}
+
+	opts.prompt = strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if opts.json {
+		opts.print = true
+	}
+
+	return opts, nil
+}
+
+// readPromptFromStdin reads the query for print mode when it's piped in rather than given as args
+func readPromptFromStdin(stdin *os.File) (string, error) {
+	info, err := stdin.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return "", fmt.Errorf("--print requires a query, as args or on stdin")
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("read query from stdin: %w", err)
+	}
+
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" {
+		return "", fmt.Errorf("--print requires a query, stdin was empty")
+	}
+
+	return prompt, nil
+}
+
+func getVersion() string {
+	if version != "" {
+		return version
+	}
+
+	// `go install` builds embed the module version instead
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return "dev"
+}
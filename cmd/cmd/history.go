@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/exitcode"
+	"github.com/azvaliev/cmd/internal/pkg/history"
+	"github.com/azvaliev/cmd/internal/pkg/xdg"
+)
+
+const historyUsage = `Search the commands cmd has generated, oldest first.
+
+Usage:
+  cmd history [flags] [--] [text...]
+  cmd history --open <id>
+  cmd history --run <id>
+
+Text matches the prompt or the command, ignoring case.
+Dates are YYYY-MM-DD, or how long ago, e.g. 12h or 7d.
+
+Flags:
+`
+
+const DEFAULT_HISTORY_LIMIT = 20
+
+// isHistoryCommand reports whether args invoke `cmd history`. Anything after it
+// has to be a flag (or "--"), so a query like "history of this file" is still a query.
+func isHistoryCommand(args []string) bool {
+	return len(args) >= 1 && args[0] == "history" && (len(args) == 1 || strings.HasPrefix(args[1], "-"))
+}
+
+func runHistoryCommand(args []string, output io.Writer) int {
+	var filter history.Filter
+	var limit, openID, runID int
+
+	flags := flag.NewFlagSet("cmd history", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Func("exit", "only commands that exited with this code", func(value string) error {
+		code, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		filter.ExitCode = &code
+		return nil
+	})
+	flags.Func("since", "only commands from this date on", func(value string) (err error) {
+		filter.Since, err = parseHistoryTime(value, false)
+		return err
+	})
+	flags.Func("until", "only commands up to this date", func(value string) (err error) {
+		filter.Until, err = parseHistoryTime(value, true)
+		return err
+	})
+	flags.Func("dir", "only commands run in this directory, . for the current one", func(value string) (err error) {
+		filter.Dir, err = filepath.Abs(xdg.ExpandHome(value))
+		return err
+	})
+	flags.IntVar(&limit, "limit", DEFAULT_HISTORY_LIMIT, "show at most this many of the latest matches, 0 for all")
+	flags.IntVar(&openID, "open", 0, "open the entry with this id in the confirm screen")
+	flags.IntVar(&runID, "run", 0, "run the command from the entry with this id again")
+	flags.Usage = func() {
+		fmt.Fprint(output, historyUsage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitcode.OK
+		}
+		return exitcode.USAGE
+	}
+	filter.Text = strings.TrimSpace(strings.Join(flags.Args(), " "))
+
+	entries, err := history.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.ERROR
+	}
+
+	if openID != 0 || runID != 0 {
+		if openID != 0 && runID != 0 {
+			fmt.Fprintln(os.Stderr, "--open and --run can't be used together")
+			return exitcode.USAGE
+		}
+
+		entry, err := history.Find(entries, max(openID, runID))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitcode.USAGE
+		}
+		return run(options{entry: &entry, rerun: runID != 0})
+	}
+
+	matches := history.Search(entries, filter)
+	if limit > 0 && len(matches) > limit {
+		matches = matches[len(matches)-limit:]
+	}
+
+	for _, entry := range matches {
+		printHistoryEntry(output, entry)
+	}
+
+	return exitcode.OK
+}
+
+func printHistoryEntry(output io.Writer, entry history.Entry) {
+	fmt.Fprintf(
+		output, "%d  %s  %s  %s\n",
+		entry.ID, entry.Time.Local().Format("2006-01-02 15:04"), contractHome(entry.Dir), historyStatus(entry),
+	)
+	fmt.Fprintf(output, "    > %s\n", entry.Prompt)
+	fmt.Fprintf(output, "    %s\n\n", strings.ReplaceAll(entry.Command, "\n", "\n    "))
+}
+
+func historyStatus(entry history.Entry) string {
+	switch entry.Verdict {
+	case history.VERDICT_NOT_RUN:
+		return "not run"
+	case history.VERDICT_CANCELLED:
+		return "cancelled"
+	}
+
+	status := "exited"
+	if entry.ExitCode != nil {
+		status = fmt.Sprintf("exit %d", *entry.ExitCode)
+	}
+	if entry.Verdict == history.VERDICT_DIDNT_WORK {
+		status += ", didn't work"
+	}
+	return status
+}
+
+// parseHistoryTime reads a date (YYYY-MM-DD) or a duration ago (12h, 7d).
+// With endOfDay, a date means the end of that day, so --until includes it.
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if date, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if endOfDay {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+
+	// time.ParseDuration stops at hours
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+
+	ago, err := time.ParseDuration(value)
+	if err != nil || ago < 0 {
+		return time.Time{}, fmt.Errorf("%q is neither a date (YYYY-MM-DD) nor a duration (12h, 7d)", value)
+	}
+	return time.Now().Add(-ago), nil
+}
+
+func contractHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return filepath.Join("~", rest)
+	}
+	return path
+}
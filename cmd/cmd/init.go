@@ -0,0 +1,49 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+const initUsage = `Print the shell integration, which replaces the query typed at the prompt
+with the generated command (ctrl-g), so it runs in your shell and lands in its history.
+
+Usage:
+  cmd init zsh    add eval "$(cmd init zsh)" to ~/.zshrc
+  cmd init bash   add eval "$(cmd init bash)" to ~/.bashrc
+  cmd init fish   add cmd init fish | source to ~/.config/fish/config.fish
+`
+
+var initShells = []string{"zsh", "bash", "fish"}
+
+//go:embed shell
+var shellScripts embed.FS
+
+// isInitCommand reports whether args invoke `cmd init <shell>`,
+// so a query that merely starts with "init" is still treated as a query
+func isInitCommand(args []string) bool {
+	return len(args) >= 2 && args[0] == "init" && (slices.Contains(initShells, args[1]) || isHelpFlag(args[1]))
+}
+
+func runInitCommand(args []string, output io.Writer) int {
+	if isHelpFlag(args[0]) {
+		fmt.Fprint(output, initUsage)
+		return 0
+	}
+
+	script, err := shellScripts.ReadFile("shell/init." + args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	output.Write(script)
+	return 0
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
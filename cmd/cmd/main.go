@@ -2,80 +2,233 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 
 	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/config"
+	"github.com/azvaliev/cmd/internal/pkg/daemon"
+	"github.com/azvaliev/cmd/internal/pkg/exitcode"
+	"github.com/azvaliev/cmd/internal/pkg/rag"
+	"github.com/azvaliev/cmd/internal/pkg/risk"
+	"github.com/azvaliev/cmd/internal/pkg/sysinfo"
+	appview "github.com/azvaliev/cmd/internal/pkg/ui/views/app"
 	generateview "github.com/azvaliev/cmd/internal/pkg/ui/views/generate"
 	outputview "github.com/azvaliev/cmd/internal/pkg/ui/views/output"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
-	agentCh := make(chan generateview.AgentResult, 1)
-	serverCh := make(chan *ai.LlamaServer, 1)
-	go createAgent(agentCh, serverCh)
-	defer cleanup(serverCh)
-
-	m := generateview.NewGenerateModel(agentCh)
-	program := tea.NewProgram(m, tea.WithAltScreen())
+	if isDaemonCommand(os.Args[1:]) {
+		os.Exit(runDaemonCommand(os.Args[2:], os.Stdout))
+	}
+	if isHistoryCommand(os.Args[1:]) {
+		os.Exit(runHistoryCommand(os.Args[2:], os.Stdout))
+	}
+	if isInitCommand(os.Args[1:]) {
+		os.Exit(runInitCommand(os.Args[2:], os.Stdout))
+	}
 
-	finalModel, err := program.Run()
+	opts, err := parseFlags(os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitcode.USAGE)
 	}
 
-	result := finalModel.(generateview.GenerateModel).Result()
-	if !result.Accepted {
+	if opts.version {
+		fmt.Println("cmd", getVersion())
 		return
 	}
 
-	// Run the output view as a separate Bubble Tea program rather than transitioning
-	// within the generate program. The subprocess starts after the user confirms,
-	// and running two sequential programs keeps each view's lifecycle simple.
-	outputModel, err := outputview.NewOutputModel(result.Prompt, result.Command)
+	if opts.print && opts.prompt == "" {
+		opts.prompt, err = readPromptFromStdin(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitcode.USAGE)
+		}
+	}
+
+	// os.Exit skips deferred calls, so the real work happens in run()
+	// where the llama server cleanup is guaranteed to execute
+	os.Exit(run(opts))
+}
+
+func run(opts options) int {
+	agentCh := make(chan generateview.AgentResult, 1)
+	disposeCh := make(chan func(), 1)
+	defer cleanup(disposeCh)
+
+	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to start command:", err)
-		os.Exit(1)
+		// reported through the generate view like any other loading error
+		agentCh <- generateview.AgentResult{Err: exitcode.Wrap(exitcode.CONFIG, err)}
+		disposeCh <- func() {}
+	} else {
+		go createAgent(cfg, opts.model, agentCh, disposeCh)
+	}
+
+	if opts.print {
+		var modelName string
+		if cfg != nil {
+			// an unknown profile is reported through agentCh
+			if modelConfig, err := cfg.Model(opts.model); err == nil {
+				modelName = modelConfig.Name
+			}
+		}
+		return printCommand(agentCh, opts.prompt, modelName, opts.json)
+	}
+
+	var runOptions outputview.RunOptions
+	if cfg != nil {
+		runOptions.PTY = cfg.Run.Mode == config.RUN_MODE_PTY
+		runOptions.KillGracePeriod = cfg.Run.KillGracePeriod
+	}
+
+	m := appview.NewAppModel(agentCh, opts.prompt, runOptions, opts.noRun)
+	if opts.entry != nil {
+		m = appview.NewHistoryAppModel(agentCh, *opts.entry, runOptions, opts.noRun, opts.rerun)
 	}
-	defer outputModel.Dispose()
+	if cfg != nil {
+		// the patterns were already checked when the config loaded
+		if analyzer, err := risk.NewAnalyzer(cfg.Safety.Deny); err == nil {
+			m.UseAnalyzer(analyzer)
+		}
+		if cfg.Context.Aliases {
+			m.CheckAliases()
+		}
+	}
+	program := tea.NewProgram(m, tea.WithAltScreen())
 
-	outputProgram := tea.NewProgram(outputModel, tea.WithAltScreen(), tea.WithMouseCellMotion())
-	finalOutputModel, err := outputProgram.Run()
+	finalModel, err := program.Run()
+	appModel, _ := finalModel.(appview.AppModel)
+	// the program can end early (e.g. killed) while a command is still running
+	defer appModel.Dispose()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return exitcode.ERROR
 	}
 
-	outputResult := finalOutputModel.(outputview.OutputModel).Result()
+	result := appModel.Result()
+	if result.HistoryErr != nil {
+		fmt.Fprintln(os.Stderr, "couldn't save to history:", result.HistoryErr)
+	}
 
 	// Print to stdout after the TUI exits so it appears in the user's scrollback.
 	// The TUI uses alt-screen which vanishes on exit, so this gives a persistent record.
-	fmt.Println("->", result.Command)
-	if outputResult.Output != "" {
-		fmt.Print(outputResult.Output)
+	for _, run := range result.Runs {
+		fmt.Println("->", run.Command)
+		if run.Output.Output != "" {
+			fmt.Print(run.Output.Output)
+		}
+	}
+
+	if result.Err != nil {
+		// the alt-screen is gone now, so repeat the error where it stays visible
+		fmt.Fprintln(os.Stderr, "Error:", result.Err)
+		return exitcode.From(result.Err)
+	}
+
+	if result.Cancelled {
+		return exitcode.CANCELLED
+	}
+
+	if result.Command != "" {
+		fmt.Println("->", result.Command)
 	}
+
+	// like a shell, the last command run decides the exit status
+	if len(result.Runs) > 0 {
+		return result.Runs[len(result.Runs)-1].Output.ExitCode
+	}
+
+	return exitcode.OK
 }
 
-// we want to create it asynchronously to avoid blocking the UI
-func createAgent(agentCh chan<- generateview.AgentResult, serverCh chan<- *ai.LlamaServer) {
-	server, err := ai.CreateLLamaServer(ai.IBM_GRANITE_MODEL_CONFIG)
+// we want to create it asynchronously to avoid blocking the UI.
+// Errors are reported through agentCh, so they show up in the generate view.
+// Whatever was started (llama servers) is handed back through disposeCh, even on failure.
+func createAgent(cfg *config.Config, modelName string, agentCh chan<- generateview.AgentResult, disposeCh chan<- func()) {
+	// collected while the model loads, it can take a moment when the cache is stale
+	systemInfoCh := make(chan sysinfo.Info, 1)
+	go func() {
+		systemInfoCh <- sysinfo.Load(context.Background())
+	}()
+
+	var disposers []func()
+	defer func() {
+		disposeCh <- func() {
+			for _, dispose := range disposers {
+				dispose()
+			}
+		}
+	}()
+
+	modelConfig, err := cfg.Model(modelName)
 	if err != nil {
-		serverCh <- nil
-		agentCh <- generateview.AgentResult{Err: err}
+		agentCh <- generateview.AgentResult{Err: exitcode.Wrap(exitcode.CONFIG, err)}
 		return
 	}
 
-	serverCh <- server
-	agent := ai.NewCommandAgent(server, context.Background())
+	// reuse the daemon's server when one is running this model, otherwise spawn our own
+	provider, err := daemon.Attach(modelConfig)
+	if provider == nil || err != nil {
+		provider, err = ai.CreateProvider(modelConfig, cfg.LlamaServer)
+	}
+	if err != nil {
+		agentCh <- generateview.AgentResult{Err: exitcode.Wrap(exitcode.BACKEND_UNAVAILABLE, err)}
+		return
+	}
+	disposers = append(disposers, provider.Dispose)
+
+	agent := ai.NewCommandAgent(provider, context.Background(), <-systemInfoCh)
+
+	if cfg.Context.Directory {
+		if dir, err := os.Getwd(); err == nil {
+			agent.UseWorkingDirectory(dir, cfg.Context.MaxChars)
+		}
+	}
+
+	if cfg.RAG.EmbeddingModel != "" {
+		retriever, dispose, err := createRetriever(cfg)
+		if dispose != nil {
+			disposers = append(disposers, dispose)
+		}
+		if err != nil {
+			agentCh <- generateview.AgentResult{Err: err}
+			return
+		}
+		agent.UseRetriever(retriever)
+	}
+
 	agentCh <- generateview.AgentResult{Agent: agent}
 }
 
-// ALWAYS cleanup the llama server
-func cleanup(serverCh <-chan *ai.LlamaServer) {
-	server := <-serverCh
-	if server != nil {
-		server.Dispose()
+func createRetriever(cfg *config.Config) (*rag.Retriever, func(), error) {
+	embeddingModelConfig, err := cfg.Model(cfg.RAG.EmbeddingModel)
+	if err != nil {
+		return nil, nil, exitcode.Wrap(exitcode.CONFIG, err)
+	}
+
+	embedder, err := ai.CreateEmbedder(embeddingModelConfig, cfg.LlamaServer)
+	if err != nil {
+		return nil, nil, exitcode.Wrap(exitcode.BACKEND_UNAVAILABLE, err)
+	}
+
+	index, err := rag.LoadIndex()
+	if err != nil {
+		return nil, embedder.Dispose, fmt.Errorf("load index %s: %w", rag.IndexPath(), err)
 	}
+
+	return rag.NewRetriever(index, embedder, cfg.RAG.Threshold, cfg.RAG.MaxResults), embedder.Dispose, nil
+}
+
+// ALWAYS cleanup, createAgent may have spawned llama servers
+func cleanup(disposeCh <-chan func()) {
+	dispose := <-disposeCh
+	dispose()
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/exitcode"
+	generateview "github.com/azvaliev/cmd/internal/pkg/ui/views/generate"
+)
+
+// printResult is the --json output, meant to be stable for editor and CI integrations
+type printResult struct {
+	Prompt      string `json:"prompt"`
+	Command     string `json:"command"`
+	Explanation string `json:"explanation"`
+	// the profile's name, see ai.ModelConfig.Name
+	Model string `json:"model"`
+	// time spent generating, excluding loading the model
+	LatencyMS int64 `json:"latency_ms"`
+}
+
+// printCommand generates a single command without the TUI and writes only it to stdout,
+// or with asJSON, the command along with its explanation and modelName
+func printCommand(agentCh <-chan generateview.AgentResult, prompt, modelName string, asJSON bool) int {
+	agentResult := <-agentCh
+	if agentResult.Err != nil {
+		fmt.Fprintln(os.Stderr, agentResult.Err)
+		return exitcode.From(agentResult.Err)
+	}
+	agent := agentResult.Agent
+
+	ctx := context.Background()
+	start := time.Now()
+
+	command, err := agent.Generate(ctx, prompt, nil)
+	if errors.Is(err, ai.ErrNoContext) {
+		// teaching needs a back-and-forth, so it's only available interactively
+		fmt.Fprintln(os.Stderr, err, "- run cmd without --print to teach it a command")
+		return exitcode.GENERATE_FAILED
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.GENERATE_FAILED
+	}
+
+	if !asJSON {
+		fmt.Println(command)
+		return exitcode.OK
+	}
+
+	explanation, err := agent.Explain(ctx, prompt, command, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.GENERATE_FAILED
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	// commands are full of <, > and &, keep them readable
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(printResult{
+		Prompt:      prompt,
+		Command:     command,
+		Explanation: explanation,
+		Model:       modelName,
+		LatencyMS:   time.Since(start).Milliseconds(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitcode.ERROR
+	}
+
+	return exitcode.OK
+}
@@ -0,0 +1,18 @@
+# cmd shell integration for bash 4+, add to ~/.bashrc:
+#   eval "$(cmd init bash)"
+#
+# Type a query at the prompt and press ctrl-g to replace it with the generated command.
+# Rebind with: bind -x '"<key>": _cmd_widget'
+
+_cmd_widget() {
+  [[ -z "$READLINE_LINE" ]] && return
+
+  local generated
+  if generated=$(command cmd --print -- "$READLINE_LINE" </dev/null); then
+    READLINE_LINE=$generated
+    READLINE_POINT=${#READLINE_LINE}
+  fi
+}
+
+bind -m emacs -x '"\C-g": _cmd_widget'
+bind -m vi-insert -x '"\C-g": _cmd_widget'
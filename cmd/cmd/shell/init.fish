@@ -0,0 +1,17 @@
+# cmd shell integration for fish, add to ~/.config/fish/config.fish:
+#   cmd init fish | source
+#
+# Type a query at the prompt and press ctrl-g to replace it with the generated command.
+# Rebind with: bind <key> _cmd_widget
+
+function _cmd_widget
+    set -l query (commandline)
+    test -z "$query"; and return
+
+    set -l generated (command cmd --print -- "$query" </dev/null | string collect)
+    and commandline --replace -- $generated
+    commandline -f repaint
+end
+
+bind \cg _cmd_widget
+bind -M insert \cg _cmd_widget
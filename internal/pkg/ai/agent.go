@@ -2,40 +2,86 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
+	"github.com/azvaliev/cmd/internal/pkg/rag"
+	"github.com/azvaliev/cmd/internal/pkg/shell"
+	"github.com/azvaliev/cmd/internal/pkg/sysinfo"
+	"github.com/azvaliev/cmd/internal/pkg/workdir"
 	"github.com/firebase/genkit/go/ai"
-	"github.com/firebase/genkit/go/core/api"
 	"github.com/firebase/genkit/go/genkit"
 )
 
+// modelDefiner is implemented by providers whose models can only be
+// registered once genkit is initialized
+type modelDefiner interface {
+	DefineModels(g *genkit.Genkit)
+}
+
+// generationConfigurer is implemented by providers which take the sampling params
+// with each request, rather than when the model is loaded
+type generationConfigurer interface {
+	GenerationConfig() any
+}
+
 type CommandAgent struct {
-	genkit   *genkit.Genkit
-	context  context.Context
+	genkit    *genkit.Genkit
+	modelName string
+	// sent with each request, nil when the provider doesn't need it, see generationConfigurer
+	config any
+
+	// a cancelled request can still be unwinding when the next one starts,
+	// so turns are serialized to keep the message history in order
+	mu       sync.Mutex
 	messages []*ai.Message
+
+	// nil when RAG isn't configured
+	retriever *rag.Retriever
+
+	// described in the system prompts, so commands fit the user's system
+	systemInfo sysinfo.Info
+
+	// described with each query when set, see UseWorkingDirectory
+	workingDir         string
+	workingDirMaxChars int
 }
 
 func NewCommandAgent(
-	provider api.Plugin,
+	provider Provider,
 	context context.Context,
+	systemInfo sysinfo.Info,
 ) *CommandAgent {
 	g := genkit.Init(
 		context,
 		genkit.WithPlugins(
 			provider,
 		),
-		genkit.WithDefaultModel(fmt.Sprint(PROVIDER_NAME, "/", MODEL_NAME)),
+		genkit.WithDefaultModel(provider.ModelName()),
 	)
 
+	if definer, ok := provider.(modelDefiner); ok {
+		definer.DefineModels(g)
+	}
+
+	var config any
+	if configurer, ok := provider.(generationConfigurer); ok {
+		config = configurer.GenerationConfig()
+	}
+
 	return &CommandAgent{
-		genkit:  g,
-		context: context,
+		genkit:     g,
+		modelName:  provider.ModelName(),
+		config:     config,
+		systemInfo: systemInfo,
 		messages: []*ai.Message{
 			{
 				Role: ai.RoleSystem,
 				Content: []*ai.Part{
 					{
-						Text: getCommandGenerationSystemPrompt(),
+						Text: getCommandGenerationSystemPrompt(systemInfo),
 					},
 				},
 			},
@@ -43,44 +89,194 @@ func NewCommandAgent(
 	}
 }
 
-func (a *CommandAgent) Generate(prompt string) (string, error) {
-	a.messages = append(a.messages, &ai.Message{
+// ModelName is the genkit name of the model generating commands, e.g. "llama.cpp/default"
+func (a *CommandAgent) ModelName() string {
+	return a.modelName
+}
+
+// UseRetriever turns on retrieval, so matching index records are included with each prompt
+func (a *CommandAgent) UseRetriever(retriever *rag.Retriever) {
+	a.retriever = retriever
+}
+
+// UseWorkingDirectory describes dir's contents (in up to maxChars) with each query,
+// so file names mentioned in it can be matched up with the real ones
+func (a *CommandAgent) UseWorkingDirectory(dir string, maxChars int) {
+	a.workingDir = dir
+	a.workingDirMaxChars = maxChars
+}
+
+// StreamCallback receives generated text as it arrives, one chunk at a time
+type StreamCallback func(chunk string)
+
+// withConfig passes the provider's generation config, when it has one
+func (a *CommandAgent) withConfig() []ai.GenerateOption {
+	if a.config == nil {
+		return nil
+	}
+	return []ai.GenerateOption{ai.WithConfig(a.config)}
+}
+
+// withStreaming forwards chunks to onChunk, or leaves the request unstreamed when it's nil
+func withStreaming(onChunk StreamCallback) []ai.GenerateOption {
+	if onChunk == nil {
+		return nil
+	}
+
+	return []ai.GenerateOption{
+		ai.WithStreaming(func(ctx context.Context, chunk *ai.ModelResponseChunk) error {
+			onChunk(chunk.Text())
+			return nil
+		}),
+	}
+}
+
+var (
+	// ErrNoContext means retrieval found nothing relevant, so the command needs to be taught
+	ErrNoContext = errors.New("no matching commands in the index")
+	// ErrUnknownCommand means the model answered "IDK"
+	ErrUnknownCommand = errors.New("the model doesn't know a command for this")
+)
+
+// Generate produces a command for prompt. The full text is returned once done,
+// and is recorded in the conversation so follow-up turns have context.
+// Cancelling ctx aborts the request and leaves the conversation as it was before the call.
+//
+// With retrieval on, a prompt without matching records fails with ErrNoContext
+// rather than letting the model guess, see Teach.
+func (a *CommandAgent) Generate(ctx context.Context, prompt string, onChunk StreamCallback) (string, error) {
+	if a.retriever == nil {
+		return a.commandTurn(ctx, a.userTurn(ctx, prompt, nil), onChunk)
+	}
+
+	matches, err := a.retriever.Retrieve(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", ErrNoContext
+	}
+
+	return a.commandTurn(ctx, a.userTurn(ctx, prompt, matches), onChunk)
+}
+
+// userTurn puts what's known about the query ahead of it: the matching records,
+// and the working directory when it's described, see UseWorkingDirectory
+func (a *CommandAgent) userTurn(ctx context.Context, prompt string, matches []rag.Match) string {
+	var sections []string
+
+	if len(matches) > 0 {
+		sections = append(sections, retrievedContext(matches))
+	}
+
+	if a.workingDir != "" {
+		// a directory that can't be read is left out rather than failing the query
+		if description, err := workdir.Describe(ctx, a.workingDir, prompt, a.workingDirMaxChars); err == nil && description != "" {
+			sections = append(sections, description)
+		}
+	}
+
+	if len(sections) == 0 {
+		return prompt
+	}
+	return strings.Join(sections, "\n\n") + "\n\nQuery: " + prompt
+}
+
+func retrievedContext(matches []rag.Match) string {
+	var sb strings.Builder
+	sb.WriteString("These commands may be relevant:\n")
+	for _, match := range matches {
+		record := match.Record
+		fmt.Fprintf(&sb, "\n## %s\n%s\n", record.Command, record.Description)
+		if record.Example != "" {
+			fmt.Fprintf(&sb, "Example: %s\n", record.Example)
+		}
+		if record.HelpSummary != "" {
+			fmt.Fprintf(&sb, "Usage:\n%s\n", record.HelpSummary)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// commandTurn is a turn answered with a command, which is normalized (see shell.Normalize).
+// A command the shell can't parse gets one follow-up turn asking for a fix, which isn't
+// streamed since the chunks so far already show the broken command.
+func (a *CommandAgent) commandTurn(ctx context.Context, text string, onChunk StreamCallback) (string, error) {
+	reply, err := a.turn(ctx, text, onChunk)
+	if err != nil {
+		return "", err
+	}
+
+	command := shell.Normalize(reply)
+	_, parseErr := shell.Parse(command)
+	if parseErr == nil {
+		return command, nil
+	}
+
+	reply, err = a.turn(ctx, fmt.Sprintf("That isn't valid shell syntax: %v\nRespond with only the fixed command.", parseErr), nil)
+	// left for the user to fix, the confirm view flags it
+	if errors.Is(err, ErrUnknownCommand) {
+		return command, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return shell.Normalize(reply), nil
+}
+
+// turn sends text as the next user message. The user message is only kept
+// if the model responds, so a failed or cancelled turn can simply be retried.
+func (a *CommandAgent) turn(ctx context.Context, text string, onChunk StreamCallback) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	messages := append(a.messages, &ai.Message{
 		Role: ai.RoleUser,
 		Content: []*ai.Part{
 			{
-				Text: prompt,
+				Text: text,
 			},
 		},
 	})
 
-	res, err := genkit.Generate(
-		a.context,
-		a.genkit,
-		ai.WithMessages(a.messages...),
+	opts := append(
+		[]ai.GenerateOption{ai.WithMessages(messages...)},
+		withStreaming(onChunk)...,
 	)
+	opts = append(opts, a.withConfig()...)
+	res, err := genkit.Generate(ctx, a.genkit, opts...)
 
 	if err != nil {
 		return "", err
 	}
 
-	a.messages = append(a.messages, res.Message)
+	// the system prompt asks for IDK instead of a guess, which is never worth keeping in the history
+	if isIDK(res.Text()) {
+		return "", ErrUnknownCommand
+	}
+
+	a.messages = append(messages, res.Message)
 
 	return res.Text(), nil
 }
 
-func (a *CommandAgent) Explain(prompt string, command string) (string, error) {
-	res, err := genkit.Generate(
-		a.context,
-		a.genkit,
-		ai.WithSystem(getExplainSystemPrompt()),
-		ai.WithPrompt(
-			fmt.Sprint(
-				"The user asked for a command to do the following: ", prompt, "\n",
-				"The command generated was: ", command, "\n",
-				"Explain what this command does.",
+func (a *CommandAgent) Explain(ctx context.Context, prompt string, command string, onChunk StreamCallback) (string, error) {
+	opts := append(
+		[]ai.GenerateOption{
+			ai.WithSystem(getExplainSystemPrompt(a.systemInfo)),
+			ai.WithPrompt(
+				fmt.Sprint(
+					"The user asked for a command to do the following: ", prompt, "\n",
+					"The command generated was: ", command, "\n",
+					"Explain what this command does.",
+				),
 			),
-		),
+		},
+		withStreaming(onChunk)...,
 	)
+	opts = append(opts, a.withConfig()...)
+	res, err := genkit.Generate(ctx, a.genkit, opts...)
 
 	if err != nil {
 		return "", err
@@ -89,22 +285,30 @@ func (a *CommandAgent) Explain(prompt string, command string) (string, error) {
 	return res.Text(), nil
 }
 
-func getCommandGenerationSystemPrompt() string {
+func isIDK(text string) bool {
+	text = strings.TrimSpace(text)
+	text = strings.TrimRight(text, ".!")
+	return strings.EqualFold(text, "IDK")
+}
+
+func getCommandGenerationSystemPrompt(systemInfo sysinfo.Info) string {
 	return `You are a command generating assistant. The user will give you a query you will generate a command to execute.
 Your output should either by ONLY a command, or if you cannot produce the command then respond with "IDK"
 
 If the command requires a specific directory and the user has not provided one, use the current directory (".").
+Commands should work on the user's system, with the flags its utilities support:
+` + systemInfo.Describe() + `
 
-IMPORTANT: when outputting a command, DO NOT include any additional text or formatting like quotes or backticks` +
-		fmt.Sprint(`The user is in a`, getShell(), `shell`)
+IMPORTANT: when outputting a command, DO NOT include any additional text or formatting like quotes or backticks`
 }
 
-func getExplainSystemPrompt() string {
-	return `You are a command explanation assistant, operating in a MacOS terminal.
+func getExplainSystemPrompt(systemInfo sysinfo.Info) string {
+	return `You are a command explanation assistant, operating in a terminal on this system:
+` + systemInfo.Describe() + `
+
 Based on the provided command, explain what it does.
 The user is a technical person (Software Engineer), so keep your explanation concise and to the point.
 
 IMPORTANT: your output should only include a single explanation, no command or additional text.
-Do not include any backticks or other special characters either` +
-		fmt.Sprint(`The user is in a`, getShell(), `shell`)
+Do not include any backticks or other special characters either`
 }
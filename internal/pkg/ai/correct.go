@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Enough of the output to show what went wrong, without crowding out the conversation
+const (
+	maxCorrectionOutputLines = 30
+	maxCorrectionOutputChars = 2000
+)
+
+// Correction describes a command that ran but didn't do what the user wanted
+type Correction struct {
+	Prompt   string
+	Command  string
+	ExitCode int
+	Output   string
+	// what the user said went wrong, may be empty
+	Feedback string
+}
+
+// Correct asks for a new command as a follow-up turn, so the model sees the original
+// request and its answer alongside what happened when the command ran
+func (a *CommandAgent) Correct(ctx context.Context, correction Correction, onChunk StreamCallback) (string, error) {
+	return a.commandTurn(ctx, correction.message(), onChunk)
+}
+
+// Without asks for a command for prompt again, as a follow-up turn, avoiding tools that aren't installed
+func (a *CommandAgent) Without(ctx context.Context, prompt string, tools []string, onChunk StreamCallback) (string, error) {
+	return a.commandTurn(ctx, fmt.Sprintf(
+		"These commands aren't installed: %s\nGive me a command for the original query without them: %s",
+		strings.Join(tools, ", "), prompt,
+	), onChunk)
+}
+
+func (c Correction) message() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "I ran: %s\n", c.Command)
+	fmt.Fprintf(&sb, "It exited with code %d", c.ExitCode)
+
+	if output := tailOutput(c.Output); output != "" {
+		fmt.Fprintf(&sb, " and printed:\n%s\n", output)
+	} else {
+		sb.WriteString(" and printed nothing.\n")
+	}
+
+	if feedback := strings.TrimSpace(c.Feedback); feedback != "" {
+		fmt.Fprintf(&sb, "\nThat didn't work: %s\n", feedback)
+	} else {
+		sb.WriteString("\nThat didn't work.\n")
+	}
+
+	fmt.Fprintf(&sb, "Give me a corrected command for the original query: %s", c.Prompt)
+
+	return sb.String()
+}
+
+// tailOutput keeps the end of the output, which is where errors usually are
+func tailOutput(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > maxCorrectionOutputLines {
+		lines = lines[len(lines)-maxCorrectionOutputLines:]
+	}
+
+	tail := strings.TrimSpace(strings.Join(lines, "\n"))
+	if len(tail) > maxCorrectionOutputChars {
+		start := len(tail) - maxCorrectionOutputChars
+		// start on a whole rune, so the model isn't sent invalid UTF-8
+		for start < len(tail) && !utf8.RuneStart(tail[start]) {
+			start++
+		}
+		tail = tail[start:]
+	}
+
+	return tail
+}
@@ -0,0 +1,37 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTailOutput(t *testing.T) {
+	manyLines := strings.Repeat("line\n", maxCorrectionOutputLines+5)
+	longLine := strings.Repeat("x", maxCorrectionOutputChars+10)
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"short", "error: not found\n", "error: not found"},
+		{"too many lines", manyLines + "error", strings.Repeat("line\n", maxCorrectionOutputLines-1) + "error"},
+		{"too long", longLine, longLine[10:]},
+		{"too long, cut between runes", "a" + strings.Repeat("é", maxCorrectionOutputChars/2), strings.Repeat("é", maxCorrectionOutputChars/2)},
+		// "é" is 2 bytes, the cut lands in the middle of one, which is dropped
+		{"too long, cut mid-rune", strings.Repeat("é", maxCorrectionOutputChars/2+1) + "x", strings.Repeat("é", maxCorrectionOutputChars/2-1) + "x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := tailOutput(test.output)
+			if got != test.want {
+				t.Errorf("tailOutput() = %q, want %q", got, test.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("tailOutput() = %q, which isn't valid UTF-8", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OpenAICompatibleEmbedder calls the OpenAI embeddings API, which llama-server
+// (started with --embeddings), Ollama, and most inference servers expose
+type OpenAICompatibleEmbedder struct {
+	url       string
+	apiKey    string
+	model     string
+	modelName string
+	client    *http.Client
+
+	// set when we spawned a dedicated server for embeddings
+	server *LlamaServer
+}
+
+type embeddingsRequest struct {
+	Input string `json:"input"`
+	Model string `json:"model,omitempty"`
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// CreateEmbedder starts or connects to the embedding model described by modelConfig.
+// llama-server restricts itself to embeddings when serving them, so unlike
+// the other backends it can't share a server with the generation model.
+func CreateEmbedder(modelConfig ModelConfig, llamaServerPath string) (*OpenAICompatibleEmbedder, error) {
+	embedder := &OpenAICompatibleEmbedder{
+		apiKey: modelConfig.APIKey,
+		model:  modelConfig.Model,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+
+	switch modelConfig.Backend {
+	case "", BACKEND_LLAMA_SERVER:
+		binaryPath, err := FindLlamaServer(llamaServerPath)
+		if err != nil {
+			return nil, err
+		}
+
+		modelConfig.Embedding = true
+		server, err := CreateLLamaServer(binaryPath, modelConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		embedder.server = server
+		embedder.url = server.GetBaseUrl() + "/v1/embeddings"
+		embedder.modelName = fmt.Sprint(PROVIDER_NAME, "/", filepath.Base(modelConfig.ModelPath))
+	case BACKEND_OPENAI_COMPATIBLE:
+		embedder.url = strings.TrimSuffix(modelConfig.BaseURL, "/") + "/embeddings"
+		embedder.modelName = fmt.Sprint(OPENAI_COMPATIBLE_PROVIDER_NAME, "/", modelConfig.Model)
+	case BACKEND_OLLAMA:
+		baseURL := modelConfig.BaseURL
+		if baseURL == "" {
+			baseURL = DEFAULT_OLLAMA_BASE_URL
+		}
+		embedder.url = strings.TrimSuffix(baseURL, "/") + "/v1/embeddings"
+		embedder.modelName = fmt.Sprint("ollama/", modelConfig.Model)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", modelConfig.Backend)
+	}
+
+	return embedder, nil
+}
+
+func (e *OpenAICompatibleEmbedder) ModelName() string {
+	return e.modelName
+}
+
+func (e *OpenAICompatibleEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(embeddingsRequest{Input: text, Model: e.model})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings request returned bad status code: %d", res.StatusCode)
+	}
+
+	var parsed embeddingsResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode embeddings response: %w", err)
+	}
+	if len(parsed.Data) == 0 {
+		return nil, fmt.Errorf("embeddings response had no data")
+	}
+
+	return parsed.Data[0].Embedding, nil
+}
+
+func (e *OpenAICompatibleEmbedder) Dispose() {
+	if e.server != nil {
+		e.server.Dispose()
+	}
+}
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const LLAMA_SERVER_ENV_VAR = "CMD_LLAMA_SERVER"
+
+// Oldest llama.cpp build that accepts every flag CreateLLamaServer passes.
+// `--flash-attn on|off` is the newest of them (older builds treat it as a bare switch).
+const MIN_LLAMA_SERVER_BUILD = 6325
+
+const llamaServerInstallHint = `install llama.cpp (e.g. "brew install llama.cpp"), or point cmd at an existing build with
+  llama_server = "/path/to/llama-server" in the config file, or
+  ` + LLAMA_SERVER_ENV_VAR + `=/path/to/llama-server`
+
+var llamaServerVersionPattern = regexp.MustCompile(`version:\s*(\d+)`)
+
+// FindLlamaServer resolves the llama-server binary from, in order:
+// the configured path, $CMD_LLAMA_SERVER, then $PATH.
+// The binary's build is checked so an outdated install fails here with a clear message,
+// rather than llama-server exiting on an unknown flag and timing out the health check.
+func FindLlamaServer(configuredPath string) (string, error) {
+	path, source := configuredPath, "llama_server config key"
+	if path == "" {
+		path, source = os.Getenv(LLAMA_SERVER_ENV_VAR), LLAMA_SERVER_ENV_VAR
+	}
+
+	var err error
+	if path != "" {
+		path, err = exec.LookPath(path)
+		if err != nil {
+			return "", fmt.Errorf("llama-server from %s is not executable: %w\n%s", source, err, llamaServerInstallHint)
+		}
+	} else {
+		path, err = exec.LookPath("llama-server")
+		if err != nil {
+			return "", fmt.Errorf("llama-server not found in $PATH\n%s", llamaServerInstallHint)
+		}
+	}
+
+	build, err := getLlamaServerBuild(path)
+	if err != nil {
+		return "", err
+	}
+	// custom builds may not report a build number, give them the benefit of the doubt
+	if build != 0 && build < MIN_LLAMA_SERVER_BUILD {
+		return "", fmt.Errorf(
+			"llama-server at %s is build %d, cmd needs build %d or newer\n%s",
+			path, build, MIN_LLAMA_SERVER_BUILD, llamaServerInstallHint,
+		)
+	}
+
+	return path, nil
+}
+
+// getLlamaServerBuild parses the build number from `llama-server --version`,
+// which prints e.g. "version: 6527 (a1b2c3d)". Returns 0 when it can't be determined.
+func getLlamaServerBuild(path string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the version is written to stderr on most builds, so read both
+	output, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return 0, fmt.Errorf("%s --version timed out", path)
+	}
+	if err != nil {
+		return 0, errors.Join(fmt.Errorf("%s --version failed", path), err)
+	}
+
+	match := llamaServerVersionPattern.FindSubmatch(output)
+	if match == nil {
+		return 0, nil
+	}
+
+	build, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return 0, nil
+	}
+
+	return build, nil
+}
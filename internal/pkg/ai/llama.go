@@ -23,72 +23,46 @@ const PROVIDER_NAME string = "llama.cpp"
 const MODEL_NAME string = "default"
 
 type ModelConfig struct {
-	Name      string
-	ModelPath string
+	Name string `toml:"name"`
+	// one of the BACKEND_* constants, defaults to BACKEND_LLAMA_SERVER
+	Backend string `toml:"backend"`
+
+	// Remote backends (openai, ollama)
+	BaseURL string `toml:"base_url"`
+	APIKey  string `toml:"api_key"`
+	// model name as the remote backend knows it
+	Model string `toml:"model"`
+
+	// Spawned llama-server backend
+	ModelPath string `toml:"path"`
 	// -1 for unlimited
-	ReasoningBudget int
-	Temperature     float64
-	MinP            float64
-	TopP            float64
-	TopK            int
-	FlashAttn       bool
-	RepeatPenalty   float64
+	ReasoningBudget int `toml:"reasoning_budget"`
+	// nil leaves it to the server, 0 is greedy sampling
+	Temperature   *float64 `toml:"temperature"`
+	MinP          float64  `toml:"min_p"`
+	TopP          float64  `toml:"top_p"`
+	TopK          int      `toml:"top_k"`
+	FlashAttn     bool     `toml:"flash_attn"`
+	RepeatPenalty float64  `toml:"repeat_penalty"`
+	CtxSize       int      `toml:"ctx_size"`
+	// layers offloaded to the GPU, 0 runs entirely on CPU
+	GPULayers  int `toml:"gpu_layers"`
+	BatchSize  int `toml:"batch_size"`
+	UBatchSize int `toml:"ubatch_size"`
+	// serve embeddings instead of completions, set by CreateEmbedder
+	Embedding bool `toml:"-"`
 }
 
-// TODO: I should be able to get away with Q6 on these models, and Q4 on LFM 2.5
-
-// Qwen 3.0 1.7B
-//
-// https://huggingface.co/Qwen/Qwen3-1.7B
-var QWEN_3_MODEL_CONFIG ModelConfig = ModelConfig{
-	Name:            "Qwen3-1.7B",
-	ModelPath:       "/Users/azatvaliev/.lmstudio/models/unsloth/Qwen3-1.7B-GGUF/Qwen3-1.7B-Q8_0.gguf",
-	ReasoningBudget: 0,
-	Temperature:     0.7,
-	MinP:            0.01,
-	TopP:            0.9,
-	TopK:            20,
-	FlashAttn:       true,
-}
-
-// LFM 2.5 1.2B Instruct
-//
-// https://huggingface.co/LiquidAI/LFM2.5-1.2B-Instruct
-var LIQUIDAI_LFM_25_INSTRUCT_MODEL_CONFIG ModelConfig = ModelConfig{
-	Name:            "LFM2.5-1.2B-Instruct",
-	ModelPath:       "/Users/azatvaliev/.lmstudio/models/LiquidAI/LFM2.5-1.2B-Instruct-GGUF/LFM2.5-1.2B-Instruct-Q8_0.gguf",
-	ReasoningBudget: 0,
-	Temperature:     0.1,
-	MinP:            0.15,
-	FlashAttn:       false,
-	RepeatPenalty:   1.05,
-}
-
-// Qwen 2.5 Coder 1.2B
-//
-// https://huggingface.co/Qwen/Qwen2.5-Coder-1.5B-Instruct
-var QWEN_25_CODER_MODEL_CONFIG ModelConfig = ModelConfig{
-	Name:            "Qwen2.5-Coder-1.5B-Instruct",
-	ModelPath:       "/Users/azatvaliev/.lmstudio/models/Qwen/Qwen2.5-Coder-1.5B-Instruct-GGUF/qwen2.5-coder-1.5b-instruct-q8_0.gguf",
-	ReasoningBudget: 0,
-	Temperature:     0.7,
-	MinP:            0.01,
-	TopP:            0.9,
-	TopK:            20,
-	FlashAttn:       true,
-}
-
-// IBM Granite 4.0 H 1b
-//
-// https://huggingface.co/ibm-granite/granite-4.0-h-1b
-var IBM_GRANITE_MODEL_CONFIG ModelConfig = ModelConfig{
-	Name:            "IBM-Granite-4.0-H-1b",
-	ModelPath:       "/Users/azatvaliev/.lmstudio/models/ibm-granite/granite-4.0-h-1b-GGUF/granite-4.0-h-1b-Q8_0.gguf",
-	ReasoningBudget: 0,
-	Temperature:     0.0,
-	TopP:            1.0,
-	TopK:            0,
-	FlashAttn:       false,
+// DefaultModelConfig holds the server settings used when a profile doesn't set them.
+// Sampling params are left at llama-server's own defaults, but reasoning isn't: an unset
+// reasoning_budget is passed as 0, which turns thinking off.
+var DefaultModelConfig = ModelConfig{
+	// 4096 token context
+	CtxSize: 4096,
+	// full offload to GPU
+	GPULayers:  99,
+	BatchSize:  2048,
+	UBatchSize: 512,
 }
 
 type LlamaServer struct {
@@ -99,6 +73,8 @@ type LlamaServer struct {
 	client                 *http.Client
 }
 
+var _ Provider = (*LlamaServer)(nil)
+
 func (llamaServer *LlamaServer) GetBaseUrl() string {
 	return fmt.Sprintf("http://localhost:%d", llamaServer.port)
 }
@@ -124,8 +100,17 @@ func (llamaServer *LlamaServer) Init(ctx context.Context) []api.Action {
 	return actions
 }
 
+func (llamaServer *LlamaServer) ModelName() string {
+	return fmt.Sprint(PROVIDER_NAME, "/", MODEL_NAME)
+}
+
+func (llamaServer *LlamaServer) Port() int {
+	return llamaServer.port
+}
+
+// Dispose stops the server if we spawned it. Attached servers are left running.
 func (llamaServer *LlamaServer) Dispose() {
-	if llamaServer.cmd.Process == nil {
+	if llamaServer.cmd == nil || llamaServer.cmd.Process == nil {
 		return
 	}
 
@@ -149,7 +134,9 @@ func (llamaServer *LlamaServer) HealthCheck() error {
 	}
 }
 
-func CreateLLamaServer(modelConfig ModelConfig) (*LlamaServer, error) {
+// CreateLLamaServer spawns llama-server (see FindLlamaServer for resolving binaryPath)
+// and blocks until it's ready to serve requests
+func CreateLLamaServer(binaryPath string, modelConfig ModelConfig) (*LlamaServer, error) {
 	port, err := GetFreePort()
 	if err != nil {
 		return nil, errors.Join(
@@ -164,16 +151,14 @@ func CreateLLamaServer(modelConfig ModelConfig) (*LlamaServer, error) {
 		modelConfig.ModelPath,
 		"--port",
 		fmt.Sprintf("%d", port),
-		// 4096 token context
 		"--ctx-size",
-		"4096",
-		// full offload to GPU
+		fmt.Sprintf("%d", modelConfig.CtxSize),
 		"-ngl",
-		"99",
+		fmt.Sprintf("%d", modelConfig.GPULayers),
 		"--batch-size",
-		"2048",
+		fmt.Sprintf("%d", modelConfig.BatchSize),
 		"--ubatch-size",
-		"512",
+		fmt.Sprintf("%d", modelConfig.UBatchSize),
 		// we will only run 1 request at a time
 		"--parallel",
 		"1",
@@ -184,10 +169,16 @@ func CreateLLamaServer(modelConfig ModelConfig) (*LlamaServer, error) {
 		fmt.Sprintf("%d", modelConfig.ReasoningBudget),
 	)
 
-	args = append(args,
-		"--temp",
-		fmt.Sprintf("%f", modelConfig.Temperature),
-	)
+	if modelConfig.Temperature != nil {
+		args = append(args,
+			"--temp",
+			fmt.Sprintf("%f", *modelConfig.Temperature),
+		)
+	}
+
+	if modelConfig.Embedding {
+		args = append(args, "--embeddings")
+	}
 
 	if modelConfig.FlashAttn {
 		args = append(args,
@@ -230,10 +221,7 @@ func CreateLLamaServer(modelConfig ModelConfig) (*LlamaServer, error) {
 	}
 
 	var cmdOutput bytes.Buffer
-	cmd := exec.Command(
-		"/Users/azatvaliev/dev/llama.cpp/build/bin/llama-server",
-		args...,
-	)
+	cmd := exec.Command(binaryPath, args...)
 	cmd.Stdout = &cmdOutput
 	cmd.Stderr = &cmdOutput
 
@@ -256,18 +244,7 @@ func CreateLLamaServer(modelConfig ModelConfig) (*LlamaServer, error) {
 		return nil, err
 	}
 
-	llamaServer := &LlamaServer{
-		modelConfig: modelConfig,
-		cmd:         cmd,
-		port:        port,
-		OpenAICompatiblePlugin: compat_oai.OpenAICompatible{
-			Provider: PROVIDER_NAME,
-			BaseURL:  fmt.Sprintf("http://localhost:%d", port),
-		},
-		client: &http.Client{
-			Timeout: 50 * time.Millisecond,
-		},
-	}
+	llamaServer := newLlamaServer(modelConfig, cmd, port)
 
 	var healthcheckError error
 	for poll := range 200 {
@@ -287,6 +264,32 @@ func CreateLLamaServer(modelConfig ModelConfig) (*LlamaServer, error) {
 	return nil, errors.Join(errors.New("llama-server failed to start"), healthcheckError)
 }
 
+// AttachLlamaServer connects to a llama-server that's already running on port,
+// such as one kept alive by the daemon, instead of spawning a new one
+func AttachLlamaServer(modelConfig ModelConfig, port int) (*LlamaServer, error) {
+	llamaServer := newLlamaServer(modelConfig, nil, port)
+	if err := llamaServer.HealthCheck(); err != nil {
+		return nil, err
+	}
+
+	return llamaServer, nil
+}
+
+func newLlamaServer(modelConfig ModelConfig, cmd *exec.Cmd, port int) *LlamaServer {
+	return &LlamaServer{
+		modelConfig: modelConfig,
+		cmd:         cmd,
+		port:        port,
+		OpenAICompatiblePlugin: compat_oai.OpenAICompatible{
+			Provider: PROVIDER_NAME,
+			BaseURL:  fmt.Sprintf("http://localhost:%d", port),
+		},
+		client: &http.Client{
+			Timeout: 50 * time.Millisecond,
+		},
+	}
+}
+
 // GetFreePort asks the kernel for a free open port that is ready to use.
 func GetFreePort() (port int, err error) {
 	var a *net.TCPAddr
@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/firebase/genkit/go/core/api"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/ollama"
+)
+
+const DEFAULT_OLLAMA_BASE_URL = "http://localhost:11434"
+
+// OllamaProvider generates with a model pulled into a running Ollama instance
+type OllamaProvider struct {
+	modelConfig ModelConfig
+	plugin      *ollama.Ollama
+}
+
+var _ Provider = (*OllamaProvider)(nil)
+
+func NewOllamaProvider(modelConfig ModelConfig) *OllamaProvider {
+	baseURL := modelConfig.BaseURL
+	if baseURL == "" {
+		baseURL = DEFAULT_OLLAMA_BASE_URL
+	}
+
+	return &OllamaProvider{
+		modelConfig: modelConfig,
+		plugin: &ollama.Ollama{
+			ServerAddress: baseURL,
+		},
+	}
+}
+
+// Implement GenKit Plugin
+func (p *OllamaProvider) Name() string {
+	return p.plugin.Name()
+}
+
+// Implement GenKit Plugin
+func (p *OllamaProvider) Init(ctx context.Context) []api.Action {
+	return p.plugin.Init(ctx)
+}
+
+// Unlike compat_oai, the Ollama plugin can only define models on an initialized genkit instance
+func (p *OllamaProvider) DefineModels(g *genkit.Genkit) {
+	p.plugin.DefineModel(g, ollama.ModelDefinition{
+		Name: p.modelConfig.Model,
+		Type: "chat",
+	}, nil)
+}
+
+func (p *OllamaProvider) ModelName() string {
+	return fmt.Sprint(p.plugin.Name(), "/", p.modelConfig.Model)
+}
+
+// the Ollama daemon manages model lifetimes itself, nothing to clean up
+func (p *OllamaProvider) Dispose() {}
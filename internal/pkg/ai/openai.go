@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core/api"
+	"github.com/firebase/genkit/go/plugins/compat_oai"
+	"github.com/openai/openai-go"
+)
+
+const OPENAI_COMPATIBLE_PROVIDER_NAME string = "openai-compatible"
+
+// OpenAICompatibleProvider talks to an already running server exposing the OpenAI chat API,
+// e.g. a shared llama-server, vLLM, or LM Studio instance
+type OpenAICompatibleProvider struct {
+	modelConfig ModelConfig
+	plugin      compat_oai.OpenAICompatible
+}
+
+var _ Provider = (*OpenAICompatibleProvider)(nil)
+
+func NewOpenAICompatibleProvider(modelConfig ModelConfig) *OpenAICompatibleProvider {
+	return &OpenAICompatibleProvider{
+		modelConfig: modelConfig,
+		plugin: compat_oai.OpenAICompatible{
+			Provider: OPENAI_COMPATIBLE_PROVIDER_NAME,
+			BaseURL:  modelConfig.BaseURL,
+			APIKey:   modelConfig.APIKey,
+		},
+	}
+}
+
+// Implement GenKit Plugin
+func (p *OpenAICompatibleProvider) Name() string {
+	return OPENAI_COMPATIBLE_PROVIDER_NAME
+}
+
+// Implement GenKit Plugin
+func (p *OpenAICompatibleProvider) Init(ctx context.Context) []api.Action {
+	actions := p.plugin.Init(ctx)
+
+	actions = append(actions, p.plugin.DefineModel(OPENAI_COMPATIBLE_PROVIDER_NAME, p.modelConfig.Model, ai.ModelOptions{
+		Label:    p.modelConfig.Name,
+		Supports: &compat_oai.BasicText,
+		Versions: []string{p.modelConfig.Model},
+	}).(api.Action))
+
+	return actions
+}
+
+// GenerationConfig sends the profile's sampling params with each request, like llama-server gets them
+// on startup. Only the ones that are set are sent, the rest are left to the server's defaults.
+// min_p, top_k and repeat_penalty aren't part of the OpenAI API, only some servers accept them (llama-server, vLLM).
+func (p *OpenAICompatibleProvider) GenerationConfig() any {
+	params := &openai.ChatCompletionNewParams{}
+	if p.modelConfig.Temperature != nil {
+		params.Temperature = openai.Float(*p.modelConfig.Temperature)
+	}
+	if p.modelConfig.TopP != 0 {
+		params.TopP = openai.Float(p.modelConfig.TopP)
+	}
+
+	extra := make(map[string]any)
+	if p.modelConfig.MinP != 0 {
+		extra["min_p"] = p.modelConfig.MinP
+	}
+	if p.modelConfig.TopK != 0 {
+		extra["top_k"] = p.modelConfig.TopK
+	}
+	if p.modelConfig.RepeatPenalty != 0 {
+		extra["repeat_penalty"] = p.modelConfig.RepeatPenalty
+	}
+	if len(extra) > 0 {
+		params.SetExtraFields(extra)
+	}
+
+	return params
+}
+
+func (p *OpenAICompatibleProvider) ModelName() string {
+	return fmt.Sprint(OPENAI_COMPATIBLE_PROVIDER_NAME, "/", p.modelConfig.Model)
+}
+
+// the server isn't ours, nothing to clean up
+func (p *OpenAICompatibleProvider) Dispose() {}
@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/firebase/genkit/go/core/api"
+)
+
+const (
+	BACKEND_LLAMA_SERVER      = "llama-server"
+	BACKEND_OPENAI_COMPATIBLE = "openai"
+	BACKEND_OLLAMA            = "ollama"
+)
+
+// Provider is an inference backend the CommandAgent generates with
+type Provider interface {
+	api.Plugin
+	// fully qualified genkit model name, e.g. "llama.cpp/default"
+	ModelName() string
+	// release anything the provider started, safe to call more than once
+	Dispose()
+}
+
+// CreateProvider starts or connects to the backend selected by modelConfig.Backend.
+// llamaServerPath is only used by the llama-server backend, see FindLlamaServer.
+func CreateProvider(modelConfig ModelConfig, llamaServerPath string) (Provider, error) {
+	switch modelConfig.Backend {
+	case "", BACKEND_LLAMA_SERVER:
+		binaryPath, err := FindLlamaServer(llamaServerPath)
+		if err != nil {
+			return nil, err
+		}
+		return CreateLLamaServer(binaryPath, modelConfig)
+	case BACKEND_OPENAI_COMPATIBLE:
+		return NewOpenAICompatibleProvider(modelConfig), nil
+	case BACKEND_OLLAMA:
+		return NewOllamaProvider(modelConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", modelConfig.Backend)
+	}
+}
@@ -0,0 +1,172 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/azvaliev/cmd/internal/pkg/rag"
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+const helpTimeout = 5 * time.Second
+
+// Keeps the summarization prompt well inside the default 4096 token context
+const maxHelpChars = 8000
+
+// man renders bold/underline as overstrikes, e.g. "N\bNA\bAM\bME\bE"
+var overstrikePattern = regexp.MustCompile(".\b")
+
+// Teach learns tool from its --help and man page, stores it in the index (when retrieval is on),
+// and then generates a command for prompt using what was learned.
+func (a *CommandAgent) Teach(ctx context.Context, prompt string, tool string, onChunk StreamCallback) (string, error) {
+	helpText, err := ReadHelp(ctx, tool)
+	if err != nil {
+		return "", err
+	}
+
+	record, err := a.summarizeHelp(ctx, tool, helpText)
+	if err != nil {
+		return "", err
+	}
+
+	command, err := a.commandTurn(ctx, a.userTurn(ctx, prompt, []rag.Match{{Record: record}}), onChunk)
+	if err != nil {
+		return "", err
+	}
+
+	if a.retriever != nil {
+		record.Example = command
+		if err := a.retriever.Learn(ctx, record, prompt); err != nil {
+			return "", fmt.Errorf("save %s to the index: %w", tool, err)
+		}
+	}
+
+	return command, nil
+}
+
+// ReadHelp collects `tool --help` and, when there is one, its man page
+func ReadHelp(ctx context.Context, tool string) (string, error) {
+	tool = strings.TrimSpace(tool)
+	if tool == "" || strings.ContainsAny(tool, " \t/") {
+		return "", fmt.Errorf("%q isn't a command name", tool)
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return "", fmt.Errorf("%s isn't installed, or isn't in $PATH", tool)
+	}
+
+	// many tools print help to stderr and/or exit non-zero, so any output counts
+	helpOutput, _ := runHelpCommand(ctx, tool, "--help")
+
+	var manOutput string
+	if _, err := exec.LookPath("man"); err == nil {
+		// a failed man prints "No manual entry for ...", which isn't worth learning from
+		if output, err := runHelpCommand(ctx, "man", tool); err == nil {
+			manOutput = overstrikePattern.ReplaceAllString(output, "")
+		}
+	}
+
+	var sections []string
+	if helpOutput != "" {
+		sections = append(sections, helpOutput)
+	}
+	if manOutput != "" {
+		sections = append(sections, manOutput)
+	}
+	if len(sections) == 0 {
+		return "", fmt.Errorf("%s has no --help output or man page to learn from", tool)
+	}
+
+	return truncate(strings.Join(sections, "\n\n"), maxHelpChars), nil
+}
+
+// truncate cuts s to at most n bytes, on a rune boundary so a multi-byte character isn't split
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func runHelpCommand(ctx context.Context, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, helpTimeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// never wait on a pager
+	cmd.Env = append(os.Environ(), "PAGER=cat", "MANPAGER=cat", "MANWIDTH=100")
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s timed out", name)
+	}
+
+	return strings.TrimSpace(output.String()), err
+}
+
+// summarizeHelp turns raw help text into the record stored in the index
+func (a *CommandAgent) summarizeHelp(ctx context.Context, tool string, helpText string) (rag.Record, error) {
+	opts := append(
+		[]ai.GenerateOption{
+			ai.WithSystem(getHelpSummarySystemPrompt()),
+			ai.WithPrompt(fmt.Sprint("Command: ", tool, "\n\n", helpText)),
+		},
+		a.withConfig()...,
+	)
+	res, err := genkit.Generate(ctx, a.genkit, opts...)
+	if err != nil {
+		return rag.Record{}, err
+	}
+
+	description, summary := parseHelpSummary(res.Text())
+	if description == "" {
+		return rag.Record{}, fmt.Errorf("couldn't summarize the help for %s", tool)
+	}
+
+	return rag.Record{
+		Command:     tool,
+		Description: description,
+		HelpSummary: summary,
+	}, nil
+}
+
+// parseHelpSummary splits the "DESCRIPTION: ...\nUSAGE:\n..." format asked for in getHelpSummarySystemPrompt
+func parseHelpSummary(text string) (description string, summary string) {
+	text = strings.TrimSpace(text)
+
+	descriptionPart, usagePart, found := strings.Cut(text, "USAGE:")
+	if !found {
+		// small models don't always follow the format, fall back to first line + rest
+		descriptionPart, usagePart, _ = strings.Cut(text, "\n")
+	}
+
+	description = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(descriptionPart), "DESCRIPTION:"))
+	summary = strings.TrimSpace(usagePart)
+
+	return description, summary
+}
+
+func getHelpSummarySystemPrompt() string {
+	return `You summarize the help text of a command line tool.
+Respond in exactly this format:
+
+DESCRIPTION: <one sentence describing what the tool is used for, in plain language>
+USAGE:
+<the most useful options and arguments, one per line, as "-flag: what it does">
+
+Keep the usage section under 15 lines. Do not include any other text.`
+}
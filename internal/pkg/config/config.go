@@ -0,0 +1,319 @@
+// Package config loads the user's config file, which defines the model profiles cmd can run.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/rag"
+	outputview "github.com/azvaliev/cmd/internal/pkg/ui/views/output"
+	"github.com/azvaliev/cmd/internal/pkg/workdir"
+	"github.com/azvaliev/cmd/internal/pkg/xdg"
+)
+
+const CONFIG_FILE_NAME = "config.toml"
+
+// How commands are run, see RunConfig.Mode
+const (
+	RUN_MODE_PIPE = "pipe"
+	RUN_MODE_PTY  = "pty"
+)
+
+type Config struct {
+	// profile used when --model isn't passed
+	DefaultModel string                    `toml:"default_model"`
+	Models       map[string]ai.ModelConfig `toml:"models"`
+	// path to the llama-server binary, see ai.FindLlamaServer for the fallbacks
+	LlamaServer string        `toml:"llama_server"`
+	Daemon      DaemonConfig  `toml:"daemon"`
+	RAG         RAGConfig     `toml:"rag"`
+	Run         RunConfig     `toml:"run"`
+	Safety      SafetyConfig  `toml:"safety"`
+	Context     ContextConfig `toml:"context"`
+
+	path string
+}
+
+type DaemonConfig struct {
+	// shut down after going unused this long, e.g. "30m"
+	IdleTimeout time.Duration `toml:"idle_timeout"`
+}
+
+type RAGConfig struct {
+	// model profile to embed with, retrieval is off when unset
+	EmbeddingModel string `toml:"embedding_model"`
+	// minimum cosine similarity for a record to count as a match
+	Threshold  float64 `toml:"threshold"`
+	MaxResults int     `toml:"max_results"`
+}
+
+type RunConfig struct {
+	// "pipe" captures stdout and stderr separately, "pty" runs the command in a
+	// pseudo-terminal so colors, pagers and prompts (sudo, ssh) work
+	Mode string `toml:"mode"`
+	// how long a cancelled command gets to exit before it's killed, e.g. "10s", or "0s" to kill it right away
+	KillGracePeriod time.Duration `toml:"kill_grace_period"`
+}
+
+type SafetyConfig struct {
+	// regular expressions, commands matching any of them are refused outright
+	Deny []string `toml:"deny"`
+}
+
+type ContextConfig struct {
+	// describe the working directory's contents with each query, so the model sees the actual file names
+	Directory bool `toml:"directory"`
+	// how long the description can get, in characters
+	MaxChars int `toml:"max_chars"`
+	// count the shell's aliases as installed commands, which means starting an interactive shell to list them
+	Aliases bool `toml:"aliases"`
+}
+
+// Path returns the location of the config file
+func Path() string {
+	return filepath.Join(xdg.ConfigDir(), CONFIG_FILE_NAME)
+}
+
+// Load reads and validates the config file at Path()
+func Load() (*Config, error) {
+	path := Path()
+
+	var config Config
+	metadata, err := toml.DecodeFile(path, &config)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(
+			"no config file found at %s\ncreate one with at least one model profile, see the README for an example",
+			path,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	config.path = path
+	config.applyDefaults(metadata)
+
+	if err := config.validate(metadata); err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid config %s", path), err)
+	}
+
+	return &config, nil
+}
+
+// Model returns the profile with the given name, or the default profile when name is empty
+func (c *Config) Model(name string) (ai.ModelConfig, error) {
+	if name == "" {
+		name = c.DefaultModel
+	}
+
+	model, ok := c.Models[name]
+	if !ok {
+		return ai.ModelConfig{}, fmt.Errorf(
+			"unknown model %q, profiles in %s: %s",
+			name, c.path, strings.Join(c.modelNames(), ", "),
+		)
+	}
+
+	if model.Backend == "" || model.Backend == ai.BACKEND_LLAMA_SERVER {
+		if _, err := os.Stat(model.ModelPath); err != nil {
+			return ai.ModelConfig{}, fmt.Errorf("invalid config %s: models.%s.path: %w", c.path, name, err)
+		}
+	}
+
+	return model, nil
+}
+
+// applyDefaults fills in server settings the profile left out.
+// We check the metadata rather than zero values since 0 is meaningful for some keys (e.g. gpu_layers).
+func (c *Config) applyDefaults(metadata toml.MetaData) {
+	for name, model := range c.Models {
+		isDefined := func(key string) bool {
+			return metadata.IsDefined("models", name, key)
+		}
+
+		if !isDefined("name") {
+			model.Name = name
+		}
+		if !isDefined("ctx_size") {
+			model.CtxSize = ai.DefaultModelConfig.CtxSize
+		}
+		if !isDefined("gpu_layers") {
+			model.GPULayers = ai.DefaultModelConfig.GPULayers
+		}
+		if !isDefined("batch_size") {
+			model.BatchSize = ai.DefaultModelConfig.BatchSize
+		}
+		if !isDefined("ubatch_size") {
+			model.UBatchSize = ai.DefaultModelConfig.UBatchSize
+		}
+
+		model.ModelPath = xdg.ExpandHome(model.ModelPath)
+		c.Models[name] = model
+	}
+
+	c.LlamaServer = xdg.ExpandHome(c.LlamaServer)
+
+	if !metadata.IsDefined("rag", "threshold") {
+		c.RAG.Threshold = rag.DEFAULT_THRESHOLD
+	}
+	if !metadata.IsDefined("rag", "max_results") {
+		c.RAG.MaxResults = rag.DEFAULT_MAX_RESULTS
+	}
+
+	if c.Run.Mode == "" {
+		c.Run.Mode = RUN_MODE_PIPE
+	}
+	// 0 is meaningful, it kills right away
+	if !metadata.IsDefined("run", "kill_grace_period") {
+		c.Run.KillGracePeriod = outputview.DEFAULT_KILL_GRACE_PERIOD
+	}
+
+	if !metadata.IsDefined("context", "max_chars") {
+		c.Context.MaxChars = workdir.DEFAULT_MAX_CHARS
+	}
+
+	// with a single profile there's no ambiguity about which one to use
+	if c.DefaultModel == "" && len(c.Models) == 1 {
+		for name := range c.Models {
+			c.DefaultModel = name
+		}
+	}
+}
+
+func (c *Config) validate(metadata toml.MetaData) error {
+	var errs []error
+
+	if len(c.Models) == 0 {
+		errs = append(errs, errors.New("no model profiles defined, add a [models.<name>] table"))
+	}
+
+	if c.Daemon.IdleTimeout < 0 {
+		errs = append(errs, errors.New("daemon.idle_timeout must not be negative"))
+	}
+
+	if c.RAG.EmbeddingModel != "" {
+		if _, ok := c.Models[c.RAG.EmbeddingModel]; !ok {
+			errs = append(errs, fmt.Errorf("rag.embedding_model %q does not match any profile", c.RAG.EmbeddingModel))
+		}
+	}
+	if c.RAG.Threshold <= 0 || c.RAG.Threshold > 1 {
+		errs = append(errs, errors.New("rag.threshold must be greater than 0 and at most 1"))
+	}
+	if c.RAG.MaxResults <= 0 {
+		errs = append(errs, errors.New("rag.max_results must be positive"))
+	}
+
+	if c.Run.Mode != RUN_MODE_PIPE && c.Run.Mode != RUN_MODE_PTY {
+		errs = append(errs, fmt.Errorf("run.mode must be %s or %s", RUN_MODE_PIPE, RUN_MODE_PTY))
+	}
+	if c.Run.KillGracePeriod < 0 {
+		errs = append(errs, errors.New("run.kill_grace_period must not be negative"))
+	}
+
+	for i, pattern := range c.Safety.Deny {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("safety.deny[%d]: %w", i, err))
+		}
+	}
+
+	if c.Context.MaxChars <= 0 {
+		errs = append(errs, errors.New("context.max_chars must be positive"))
+	}
+
+	if c.DefaultModel == "" && len(c.Models) > 1 {
+		errs = append(errs, errors.New("default_model must be set when more than one profile is defined"))
+	} else if _, ok := c.Models[c.DefaultModel]; !ok && len(c.Models) > 0 {
+		errs = append(errs, fmt.Errorf("default_model %q does not match any profile", c.DefaultModel))
+	}
+
+	for _, name := range c.modelNames() {
+		isDefined := func(key string) bool {
+			return metadata.IsDefined("models", name, key)
+		}
+		for _, err := range validateModel(c.Models[name], isDefined) {
+			errs = append(errs, fmt.Errorf("models.%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateModel checks a profile, isDefined reports whether the config file sets one of its keys
+func validateModel(model ai.ModelConfig, isDefined func(key string) bool) []error {
+	var errs []error
+
+	switch model.Backend {
+	case "", ai.BACKEND_LLAMA_SERVER:
+		// whether it exists is only checked for the profile in use, see Model
+		if model.ModelPath == "" {
+			errs = append(errs, errors.New("path is required"))
+		}
+	case ai.BACKEND_OPENAI_COMPATIBLE:
+		if model.BaseURL == "" {
+			errs = append(errs, errors.New("base_url is required for the openai backend"))
+		}
+		if model.Model == "" {
+			errs = append(errs, errors.New("model is required for the openai backend"))
+		}
+	case ai.BACKEND_OLLAMA:
+		if model.Model == "" {
+			errs = append(errs, errors.New("model is required for the ollama backend"))
+		}
+		// the genkit plugin has no way to pass them on, they'd be silently ignored
+		if slices.ContainsFunc([]string{"temperature", "top_p", "top_k", "min_p", "repeat_penalty"}, isDefined) {
+			errs = append(errs, errors.New("temperature, top_p, top_k, min_p and repeat_penalty aren't supported by the ollama backend, set them in the model's Modelfile instead"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(
+			"backend must be one of %s, %s, %s",
+			ai.BACKEND_LLAMA_SERVER, ai.BACKEND_OPENAI_COMPATIBLE, ai.BACKEND_OLLAMA,
+		))
+	}
+
+	if model.Temperature != nil && *model.Temperature < 0 {
+		errs = append(errs, errors.New("temperature must not be negative"))
+	}
+	if model.TopP < 0 || model.TopP > 1 {
+		errs = append(errs, errors.New("top_p must be between 0 and 1"))
+	}
+	if model.MinP < 0 || model.MinP > 1 {
+		errs = append(errs, errors.New("min_p must be between 0 and 1"))
+	}
+	if model.TopK < 0 {
+		errs = append(errs, errors.New("top_k must not be negative"))
+	}
+	if model.ReasoningBudget < -1 {
+		errs = append(errs, errors.New("reasoning_budget must be -1 (unlimited) or greater"))
+	}
+	if model.CtxSize <= 0 {
+		errs = append(errs, errors.New("ctx_size must be positive"))
+	}
+	if model.GPULayers < 0 {
+		errs = append(errs, errors.New("gpu_layers must not be negative"))
+	}
+	if model.BatchSize <= 0 || model.UBatchSize <= 0 {
+		errs = append(errs, errors.New("batch_size and ubatch_size must be positive"))
+	} else if model.UBatchSize > model.BatchSize {
+		errs = append(errs, errors.New("ubatch_size must not exceed batch_size"))
+	}
+
+	return errs
+}
+
+func (c *Config) modelNames() []string {
+	names := make([]string, 0, len(c.Models))
+	for name := range c.Models {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
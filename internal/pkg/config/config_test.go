@@ -0,0 +1,167 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/ai"
+	outputview "github.com/azvaliev/cmd/internal/pkg/ui/views/output"
+)
+
+func TestValidateModel(t *testing.T) {
+	valid := func(model ai.ModelConfig) ai.ModelConfig {
+		model.CtxSize = ai.DefaultModelConfig.CtxSize
+		model.BatchSize = ai.DefaultModelConfig.BatchSize
+		model.UBatchSize = ai.DefaultModelConfig.UBatchSize
+		return model
+	}
+	float := func(f float64) *float64 {
+		return &f
+	}
+
+	tests := []struct {
+		name  string
+		model ai.ModelConfig
+		// keys set in the config file
+		defined []string
+		wantErr string
+	}{
+		{
+			name:    "openai with sampling params",
+			model:   valid(ai.ModelConfig{Backend: ai.BACKEND_OPENAI_COMPATIBLE, BaseURL: "http://localhost:8080/v1", Model: "m", Temperature: float(0.7), TopK: 20}),
+			defined: []string{"temperature", "top_k"},
+		},
+		{
+			name:    "openai without a base url",
+			model:   valid(ai.ModelConfig{Backend: ai.BACKEND_OPENAI_COMPATIBLE, Model: "m"}),
+			wantErr: "base_url is required",
+		},
+		{
+			name:  "ollama",
+			model: valid(ai.ModelConfig{Backend: ai.BACKEND_OLLAMA, Model: "m"}),
+		},
+		{
+			name:    "ollama with sampling params",
+			model:   valid(ai.ModelConfig{Backend: ai.BACKEND_OLLAMA, Model: "m", Temperature: float(0.7)}),
+			defined: []string{"temperature"},
+			wantErr: "aren't supported by the ollama backend",
+		},
+		{
+			name:    "ollama with sampling params set to 0",
+			model:   valid(ai.ModelConfig{Backend: ai.BACKEND_OLLAMA, Model: "m", Temperature: float(0), TopK: 0}),
+			defined: []string{"temperature", "top_k"},
+			wantErr: "aren't supported by the ollama backend",
+		},
+		{
+			name:    "negative temperature",
+			model:   valid(ai.ModelConfig{Backend: ai.BACKEND_OPENAI_COMPATIBLE, BaseURL: "http://localhost:8080/v1", Model: "m", Temperature: float(-1)}),
+			defined: []string{"temperature"},
+			wantErr: "temperature must not be negative",
+		},
+		{
+			name:    "llama-server without a path",
+			model:   valid(ai.ModelConfig{}),
+			wantErr: "path is required",
+		},
+		{
+			name:    "top_p out of range",
+			model:   valid(ai.ModelConfig{Backend: ai.BACKEND_OPENAI_COMPATIBLE, BaseURL: "http://localhost:8080/v1", Model: "m", TopP: 1.5}),
+			wantErr: "top_p must be between 0 and 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var messages []string
+			isDefined := func(key string) bool {
+				return slices.Contains(test.defined, key)
+			}
+			for _, err := range validateModel(test.model, isDefined) {
+				messages = append(messages, err.Error())
+			}
+			got := strings.Join(messages, "\n")
+
+			if test.wantErr == "" && got != "" {
+				t.Errorf("validateModel() = %q, want no errors", got)
+			}
+			if test.wantErr != "" && !strings.Contains(got, test.wantErr) {
+				t.Errorf("validateModel() = %q, want an error containing %q", got, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestModelChecksPathOfSelectedProfile(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(existing, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{
+		DefaultModel: "local",
+		Models: map[string]ai.ModelConfig{
+			"local":   {ModelPath: existing},
+			"missing": {ModelPath: "/nonexistent/model.gguf"},
+			"remote":  {Backend: ai.BACKEND_OPENAI_COMPATIBLE, BaseURL: "http://localhost:8080/v1", Model: "m"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"", false},
+		{"local", false},
+		{"remote", false},
+		{"missing", true},
+		{"unknown", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := c.Model(test.name)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Model(%q) error = %v, want error %v", test.name, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadKillGracePeriod(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   time.Duration
+	}{
+		{"unset", "", outputview.DEFAULT_KILL_GRACE_PERIOD},
+		{"set", "[run]\nkill_grace_period = \"10s\"\n", 10 * time.Second},
+		{"kill right away", "[run]\nkill_grace_period = \"0s\"\n", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", dir)
+
+			config := "[models.remote]\nbackend = \"openai\"\nbase_url = \"http://localhost:8080/v1\"\nmodel = \"m\"\n" + test.config
+			if err := os.MkdirAll(filepath.Dir(Path()), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(Path(), []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Run.KillGracePeriod != test.want {
+				t.Errorf("run.kill_grace_period = %s, want %s", c.Run.KillGracePeriod, test.want)
+			}
+		})
+	}
+}
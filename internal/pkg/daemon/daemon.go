@@ -0,0 +1,290 @@
+// Package daemon keeps a single llama-server alive in the background so
+// invocations of cmd can skip loading the model. It's opt-in: nothing attaches
+// to it unless it was started with `cmd daemon start`.
+//
+// The daemon advertises itself through a lock file in the runtime dir. Clients
+// touch the lock file whenever they use the server, and the daemon shuts down
+// once it hasn't been touched for the idle timeout.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/xdg"
+)
+
+const DEFAULT_IDLE_TIMEOUT = 30 * time.Minute
+
+const (
+	lockFileName = "daemon.json"
+	logFileName  = "daemon.log"
+)
+
+// how often the daemon checks for idleness and server health
+const pollInterval = 10 * time.Second
+
+// State is what the lock file holds
+type State struct {
+	PID         int            `json:"pid"`
+	Port        int            `json:"port"`
+	Profile     string         `json:"profile"`
+	Model       ai.ModelConfig `json:"model"`
+	IdleTimeout time.Duration  `json:"idle_timeout"`
+	StartedAt   time.Time      `json:"started_at"`
+	// mtime of the lock file, i.e. the last time a client used the server
+	LastUsed time.Time `json:"-"`
+}
+
+func LockPath() string {
+	return filepath.Join(xdg.RuntimeDir(), lockFileName)
+}
+
+func LogPath() string {
+	return filepath.Join(xdg.RuntimeDir(), logFileName)
+}
+
+// ReadState returns the state of a running daemon, or nil when none is running.
+// A lock file left behind by a daemon that died is removed.
+func ReadState() (*State, error) {
+	path := LockPath()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("corrupt lock file %s: %w", path, err)
+	}
+
+	if !processAlive(state.PID) {
+		os.Remove(path)
+		return nil, nil
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		state.LastUsed = info.ModTime()
+	}
+
+	return &state, nil
+}
+
+// Attach connects to the daemon's llama-server when it's running the same model config.
+// Returns nil without an error when there's no usable daemon, so the caller can spawn its own server.
+func Attach(modelConfig ai.ModelConfig) (ai.Provider, error) {
+	state, err := ReadState()
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	if state.Model != modelConfig {
+		return nil, nil
+	}
+
+	server, err := ai.AttachLlamaServer(modelConfig, state.Port)
+	if err != nil {
+		// unhealthy, e.g. still loading the model or llama-server crashed
+		return nil, nil
+	}
+
+	markUsed()
+	return &attachedServer{server}, nil
+}
+
+// attachedServer marks the daemon as used again once the session ends,
+// so the idle timeout counts from the last session rather than the first
+type attachedServer struct {
+	*ai.LlamaServer
+}
+
+func (s *attachedServer) Dispose() {
+	markUsed()
+	s.LlamaServer.Dispose()
+}
+
+func markUsed() {
+	now := time.Now()
+	os.Chtimes(LockPath(), now, now)
+}
+
+// Start launches `cmd daemon run` detached from the terminal, and waits until its server is ready
+func Start(profile string, idleTimeout time.Duration) (*State, error) {
+	state, err := ReadState()
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		return state, fmt.Errorf("daemon already running (pid %d)", state.PID)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(xdg.RuntimeDir(), 0o700); err != nil {
+		return nil, err
+	}
+	logFile, err := os.OpenFile(LogPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+
+	args := []string{"daemon", "run", "--idle-timeout", idleTimeout.String()}
+	if profile != "" {
+		args = append(args, "--model", profile)
+	}
+
+	proc := exec.Command(executable, args...)
+	proc.Stdout = logFile
+	proc.Stderr = logFile
+	// own session so closing the terminal doesn't take the daemon down with it
+	proc.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := proc.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		proc.Wait()
+		close(exited)
+	}()
+
+	// generous, the model has to load before the lock file is written
+	deadline := time.After(60 * time.Second)
+	for {
+		select {
+		case <-exited:
+			return nil, fmt.Errorf("daemon exited during startup, see %s", LogPath())
+		case <-deadline:
+			proc.Process.Signal(syscall.SIGTERM)
+			return nil, fmt.Errorf("daemon did not become ready in time, see %s", LogPath())
+		case <-time.After(100 * time.Millisecond):
+			state, err := ReadState()
+			if err == nil && state != nil && state.PID == proc.Process.Pid {
+				return state, nil
+			}
+		}
+	}
+}
+
+// Stop signals the daemon and waits for it to clean up
+func Stop() (*State, error) {
+	state, err := ReadState()
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	if err := syscall.Kill(state.PID, syscall.SIGTERM); err != nil {
+		return state, err
+	}
+
+	for range 100 {
+		if !processAlive(state.PID) {
+			return state, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return state, fmt.Errorf("daemon (pid %d) did not stop", state.PID)
+}
+
+// Run is the daemon process itself: it serves until idle, signalled, or the server dies
+func Run(profile string, modelConfig ai.ModelConfig, llamaServerPath string, idleTimeout time.Duration) error {
+	binaryPath, err := ai.FindLlamaServer(llamaServerPath)
+	if err != nil {
+		return err
+	}
+
+	server, err := ai.CreateLLamaServer(binaryPath, modelConfig)
+	if err != nil {
+		return err
+	}
+	defer server.Dispose()
+
+	state := State{
+		PID:         os.Getpid(),
+		Port:        server.Port(),
+		Profile:     profile,
+		Model:       modelConfig,
+		IdleTimeout: idleTimeout,
+		StartedAt:   time.Now(),
+	}
+	if err := writeState(state); err != nil {
+		return err
+	}
+	defer os.Remove(LockPath())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// tolerate the odd slow health check while the server is busy generating
+	failedHealthChecks := 0
+
+	for {
+		select {
+		case sig := <-signals:
+			fmt.Println("received", sig, "shutting down")
+			return nil
+		case <-ticker.C:
+			info, err := os.Stat(LockPath())
+			if err != nil {
+				return fmt.Errorf("lock file disappeared: %w", err)
+			}
+			if time.Since(info.ModTime()) > idleTimeout {
+				fmt.Println("idle for", idleTimeout, "shutting down")
+				return nil
+			}
+			if err := server.HealthCheck(); err != nil {
+				failedHealthChecks++
+				if failedHealthChecks >= 3 {
+					return errors.Join(errors.New("llama-server is unhealthy"), err)
+				}
+			} else {
+				failedHealthChecks = 0
+			}
+		}
+	}
+}
+
+// writeState writes the lock file atomically so clients never read a partial file
+func writeState(state State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(xdg.RuntimeDir(), 0o700); err != nil {
+		return err
+	}
+
+	tmpPath := LockPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, LockPath())
+}
+
+// signal 0 checks the process exists without actually signalling it
+func processAlive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
@@ -0,0 +1,58 @@
+// Package exitcode defines the exit statuses cmd ends with, see "Exit Codes" in the README.
+// When a command is run, its own exit code is passed through instead.
+package exitcode
+
+import "errors"
+
+// Codes follow sysexits.h where one fits, since those rarely collide with
+// what the commands being run exit with
+const (
+	OK = 0
+	// anything without a more specific code, e.g. the command failing to start
+	ERROR = 1
+	// bad flags or arguments
+	USAGE = 2
+	// no command was generated: the model failed, answered "IDK", or nothing matched in the index
+	GENERATE_FAILED = 65
+	// the model backend couldn't be started or reached
+	BACKEND_UNAVAILABLE = 69
+	// the config file is missing or invalid
+	CONFIG = 78
+	// the user backed out, or cancelled the command while it ran (like SIGINT in a shell)
+	CANCELLED = 130
+)
+
+// Error tags err with the code cmd should exit with because of it
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap tags err with code, returning nil for a nil err
+func Wrap(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// From returns the code err was tagged with, or ERROR when it wasn't
+func From(err error) int {
+	if err == nil {
+		return OK
+	}
+
+	var tagged *Error
+	if errors.As(err, &tagged) {
+		return tagged.Code
+	}
+	return ERROR
+}
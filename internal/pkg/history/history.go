@@ -0,0 +1,250 @@
+// Package history records every command cmd generates, one JSON object per line
+// in an append-only file under the data dir, so past commands can be searched and reused.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/xdg"
+)
+
+const HISTORY_FILE_NAME = "history.jsonl"
+
+// explanations can make for long lines, but an entry past this is skipped rather than loaded
+const maxLineBytes = 1024 * 1024
+
+// What the user made of a command, see Entry.Verdict
+const (
+	VERDICT_DONE       = "done"
+	VERDICT_DIDNT_WORK = "didnt_work"
+	VERDICT_CANCELLED  = "cancelled"
+	// accepted with --no-run
+	VERDICT_NOT_RUN = "not_run"
+)
+
+type Entry struct {
+	// line number in the history file, starting at 1. Stable since the file is only appended to.
+	ID int `json:"-"`
+
+	Time        time.Time `json:"time"`
+	Dir         string    `json:"dir"`
+	Prompt      string    `json:"prompt"`
+	Command     string    `json:"command"`
+	Explanation string    `json:"explanation,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	// what the model generated, when the user edited it before accepting
+	Generated string `json:"generated,omitempty"`
+	// Generated compared to Command, see WordDiff
+	Diff string `json:"diff,omitempty"`
+	// nil when the command wasn't run
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Verdict  string `json:"verdict"`
+}
+
+// Path returns the location of the history file
+func Path() string {
+	return filepath.Join(xdg.DataDir(), HISTORY_FILE_NAME)
+}
+
+// Append adds entry to the end of the history file, creating it if needed.
+// Each entry is a single write to a file opened for appending, so concurrent
+// cmd processes can't interleave their lines.
+func Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(xdg.DataDir(), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(Path(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	return errors.Join(err, file.Close())
+}
+
+// Load reads every entry, oldest first, returning none if there's no history yet
+func Load() ([]Entry, error) {
+	path := Path()
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+
+	reader := bufio.NewReader(file)
+	lineNumber := 0
+	for {
+		line, tooLong, err := readLine(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		lineNumber++
+
+		// like a line cut short by a crash mid-write, one entry too long to load shouldn't make the rest unreadable
+		if tooLong {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entry.ID = lineNumber
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// readLine reads the next line, without holding on to more than maxLineBytes of it.
+// tooLong reports whether the line was longer than that, in which case it's skipped over.
+func readLine(reader *bufio.Reader) ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !tooLong && len(line)+len(chunk) <= maxLineBytes {
+			line = append(line, chunk...)
+		} else {
+			tooLong = true
+			line = nil
+		}
+
+		if !isPrefix {
+			return line, tooLong, nil
+		}
+	}
+}
+
+// Find returns the entry with the given ID
+func Find(entries []Entry, id int) (Entry, error) {
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("no history entry %d", id)
+}
+
+// Filter narrows down entries, zero fields match everything
+type Filter struct {
+	// case-insensitive substring of the prompt or command
+	Text string
+	// only commands that were run and exited with this code
+	ExitCode *int
+	Since    time.Time
+	Until    time.Time
+	Dir      string
+}
+
+func (f Filter) Match(entry Entry) bool {
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(entry.Prompt), text) && !strings.Contains(strings.ToLower(entry.Command), text) {
+			return false
+		}
+	}
+	if f.ExitCode != nil && (entry.ExitCode == nil || *entry.ExitCode != *f.ExitCode) {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	if f.Dir != "" && entry.Dir != f.Dir {
+		return false
+	}
+	return true
+}
+
+// Search returns the entries matching filter, oldest first
+func Search(entries []Entry, filter Filter) []Entry {
+	var matches []Entry
+	for _, entry := range entries {
+		if filter.Match(entry) {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
+// WordDiff describes how after differs from before, word by word, in the style of
+// `git diff --word-diff=plain`: removed words as [-word-], added words as {+word+}
+func WordDiff(before, after string) string {
+	a := strings.Fields(before)
+	b := strings.Fields(after)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var words, removed, added []string
+	flush := func() {
+		if len(removed) > 0 {
+			words = append(words, "[-"+strings.Join(removed, " ")+"-]")
+		}
+		if len(added) > 0 {
+			words = append(words, "{+"+strings.Join(added, " ")+"+}")
+		}
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			words = append(words, a[i])
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	flush()
+
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,123 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWordDiff(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          string
+	}{
+		{"ls -la", "ls -la", "ls -la"},
+		{"ls -la", "ls -lah", "ls [--la-] {+-lah+}"},
+		{"rm -rf build", "rm -r build", "rm [--rf-] {+-r+} build"},
+		{"grep foo .", "grep -r foo .", "grep {+-r+} foo ."},
+		{"du -sh * | sort -h", "du -sh *", "du -sh * [-| sort -h-]"},
+		{"echo a", "printf b", "[-echo a-] {+printf b+}"},
+		{"", "ls", "{+ls+}"},
+		{"ls", "", "[-ls-]"},
+		{"ls   -la", "ls -la", "ls -la"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.before+" → "+test.after, func(t *testing.T) {
+			if got := WordDiff(test.before, test.after); got != test.want {
+				t.Errorf("WordDiff(%q, %q) = %q, want %q", test.before, test.after, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC)
+	}
+	code := func(c int) *int {
+		return &c
+	}
+
+	entry := Entry{
+		Time:     day(10),
+		Dir:      "/home/me/project",
+		Prompt:   "Find large files",
+		Command:  "find . -size +100M",
+		ExitCode: code(1),
+	}
+	notRun := entry
+	notRun.ExitCode = nil
+
+	tests := []struct {
+		name   string
+		filter Filter
+		entry  Entry
+		want   bool
+	}{
+		{"empty filter", Filter{}, entry, true},
+		{"text in the prompt, ignoring case", Filter{Text: "LARGE"}, entry, true},
+		{"text in the command", Filter{Text: "-size"}, entry, true},
+		{"text in neither", Filter{Text: "docker"}, entry, false},
+		{"exit code", Filter{ExitCode: code(1)}, entry, true},
+		{"other exit code", Filter{ExitCode: code(0)}, entry, false},
+		{"exit code of a command that wasn't run", Filter{ExitCode: code(0)}, notRun, false},
+		{"since before", Filter{Since: day(9)}, entry, true},
+		{"since the same time", Filter{Since: day(10)}, entry, true},
+		{"since after", Filter{Since: day(11)}, entry, false},
+		{"until after", Filter{Until: day(11)}, entry, true},
+		{"until the same time is exclusive", Filter{Until: day(10)}, entry, false},
+		{"dir", Filter{Dir: "/home/me/project"}, entry, true},
+		{"other dir", Filter{Dir: "/home/me"}, entry, false},
+		{"all fields", Filter{Text: "find", ExitCode: code(1), Since: day(1), Until: day(31), Dir: "/home/me/project"}, entry, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.filter.Match(test.entry); got != test.want {
+				t.Errorf("Match() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchKeepsOrder(t *testing.T) {
+	entries := []Entry{
+		{ID: 1, Prompt: "list files", Command: "ls"},
+		{ID: 2, Prompt: "disk usage", Command: "du -sh"},
+		{ID: 3, Prompt: "list hidden files", Command: "ls -a"},
+	}
+
+	got := Search(entries, Filter{Text: "list"})
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 3 {
+		t.Errorf("Search() = %+v, want entries 1 and 3, oldest first", got)
+	}
+}
+
+func TestLoadSkipsBadLines(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	tooLong := `{"prompt":"big","command":"` + strings.Repeat("x", maxLineBytes) + `"}`
+	lines := []string{
+		`{"prompt":"first","command":"ls"}`,
+		tooLong,
+		`{"prompt":"cut short","comm`,
+		`{"prompt":"last","command":"pwd"}`,
+	}
+	if err := os.MkdirAll(filepath.Dir(Path()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(Path(), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != 1 || entries[1].ID != 4 {
+		t.Errorf("Load() = %+v, want entries 1 and 4", entries)
+	}
+}
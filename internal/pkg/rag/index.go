@@ -0,0 +1,162 @@
+// Package rag is the retrieval layer that decides whether cmd knows how to help.
+// Commands are stored as records, each reachable through several embeddings
+// (its description and the user queries that led to it), see the README's Architecture section.
+package rag
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/azvaliev/cmd/internal/pkg/xdg"
+)
+
+const INDEX_FILE_NAME = "index.json"
+
+// Record is everything we know about a command
+type Record struct {
+	Command     string   `json:"command"`
+	Description string   `json:"description"`
+	HelpSummary string   `json:"help_summary"`
+	Example     string   `json:"example"`
+	UserQueries []string `json:"user_queries"`
+}
+
+type embeddingKind string
+
+const (
+	embeddingDescription embeddingKind = "description"
+	embeddingUserQuery   embeddingKind = "user_query"
+)
+
+// embedding is one entry point into a record. The command itself is never
+// embedded, it's the payload rather than the search key.
+type embedding struct {
+	// index into Index.Records
+	Record int           `json:"record"`
+	Kind   embeddingKind `json:"kind"`
+	Text   string        `json:"text"`
+	Vector []float32     `json:"vector"`
+}
+
+type Index struct {
+	// vectors from different models aren't comparable, so the index
+	// remembers which model produced them, see Retriever.reembed
+	EmbeddingModel string      `json:"embedding_model"`
+	Records        []Record    `json:"records"`
+	Embeddings     []embedding `json:"embeddings"`
+
+	path string
+}
+
+// Match is a record that scored above the similarity threshold
+type Match struct {
+	Record Record
+	Score  float64
+}
+
+func IndexPath() string {
+	return filepath.Join(xdg.DataDir(), INDEX_FILE_NAME)
+}
+
+// LoadIndex reads the index at IndexPath(), returning an empty index if there isn't one yet
+func LoadIndex() (*Index, error) {
+	path := IndexPath()
+	index := &Index{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// Save writes the index atomically, so a crash mid-write can't corrupt it
+func (index *Index) Save() error {
+	if err := os.MkdirAll(filepath.Dir(index.path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := index.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, index.path)
+}
+
+// Search returns records with an embedding at least threshold similar to vector,
+// best match first, with at most limit results
+func (index *Index) Search(vector []float32, threshold float64, limit int) []Match {
+	// a record can be hit through several embeddings, keep its best score
+	best := make(map[int]float64)
+	for _, e := range index.Embeddings {
+		score := cosineSimilarity(vector, e.Vector)
+		if score < threshold {
+			continue
+		}
+		if current, ok := best[e.Record]; !ok || score > current {
+			best[e.Record] = score
+		}
+	}
+
+	matches := make([]Match, 0, len(best))
+	for recordIndex, score := range best {
+		matches = append(matches, Match{Record: index.Records[recordIndex], Score: score})
+	}
+	slices.SortFunc(matches, func(a, b Match) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// findRecord returns the index of the record for command, or -1
+func (index *Index) findRecord(command string) int {
+	return slices.IndexFunc(index.Records, func(r Record) bool {
+		return r.Command == command
+	})
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
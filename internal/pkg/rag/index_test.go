@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"identical", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"scaled", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, 1}, []float32{-1, -1}, -1},
+		{"45 degrees", []float32{1, 0}, []float32{1, 1}, 1 / math.Sqrt2},
+		{"different lengths", []float32{1, 2}, []float32{1, 2, 3}, 0},
+		{"empty", nil, nil, 0},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := cosineSimilarity(test.a, test.b); math.Abs(got-test.want) > 1e-6 {
+				t.Errorf("cosineSimilarity(%v, %v) = %v, want %v", test.a, test.b, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	index := &Index{
+		Records: []Record{
+			{Command: "rsync"},
+			{Command: "tar"},
+			{Command: "du"},
+		},
+		Embeddings: []embedding{
+			{Record: 0, Kind: embeddingDescription, Vector: []float32{1, 0, 0}},
+			// a second way into rsync, closer to the query than its description
+			{Record: 0, Kind: embeddingUserQuery, Vector: []float32{1, 0.1, 0}},
+			{Record: 1, Kind: embeddingDescription, Vector: []float32{1, 1, 0}},
+			{Record: 2, Kind: embeddingDescription, Vector: []float32{0, 0, 1}},
+		},
+	}
+	query := []float32{1, 0.2, 0}
+
+	tests := []struct {
+		name      string
+		threshold float64
+		limit     int
+		want      []string
+	}{
+		{"best first, each record once", 0.5, 10, []string{"rsync", "tar"}},
+		{"threshold", 0.9, 10, []string{"rsync"}},
+		{"limit", 0.5, 1, []string{"rsync"}},
+		{"nothing similar enough", 0.9999, 10, nil},
+		{"everything", -1, 10, []string{"rsync", "tar", "du"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches := index.Search(query, test.threshold, test.limit)
+
+			var got []string
+			for _, match := range matches {
+				got = append(got, match.Record.Command)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("Search() = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("Search() = %v, want %v", got, test.want)
+				}
+			}
+
+			// a record's score is its best embedding's
+			if len(matches) > 0 && matches[0].Record.Command == "rsync" {
+				want := cosineSimilarity(query, index.Embeddings[1].Vector)
+				if matches[0].Score != want {
+					t.Errorf("rsync scored %v, want its best embedding's %v", matches[0].Score, want)
+				}
+			}
+		})
+	}
+}
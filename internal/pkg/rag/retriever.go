@@ -0,0 +1,154 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+const (
+	DEFAULT_THRESHOLD   = 0.75
+	DEFAULT_MAX_RESULTS = 3
+)
+
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// identifies the model, see Index.EmbeddingModel
+	ModelName() string
+}
+
+// Retriever finds the records relevant to a query
+type Retriever struct {
+	index      *Index
+	embedder   Embedder
+	threshold  float64
+	maxResults int
+
+	// guards the index, which is shared with the teaching flow
+	mu sync.Mutex
+}
+
+func NewRetriever(index *Index, embedder Embedder, threshold float64, maxResults int) *Retriever {
+	return &Retriever{
+		index:      index,
+		embedder:   embedder,
+		threshold:  threshold,
+		maxResults: maxResults,
+	}
+}
+
+// Retrieve returns records above the similarity threshold, best first.
+// An empty result means the query is unknown territory.
+func (r *Retriever) Retrieve(ctx context.Context, query string) ([]Match, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.index.Records) == 0 {
+		return nil, nil
+	}
+
+	if err := r.reembed(ctx); err != nil {
+		return nil, err
+	}
+
+	vector, err := r.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+
+	return r.index.Search(vector, r.threshold, r.maxResults), nil
+}
+
+// reembed recomputes every vector when the embedding model has changed since the index was written.
+// Each embedding keeps its source text, so switching models doesn't lose anything.
+func (r *Retriever) reembed(ctx context.Context) error {
+	if r.index.EmbeddingModel == r.embedder.ModelName() {
+		return nil
+	}
+
+	for i := range r.index.Embeddings {
+		vector, err := r.embedder.Embed(ctx, r.index.Embeddings[i].Text)
+		if err != nil {
+			return fmt.Errorf("re-embed index for %s: %w", r.embedder.ModelName(), err)
+		}
+		r.index.Embeddings[i].Vector = vector
+	}
+
+	r.index.EmbeddingModel = r.embedder.ModelName()
+	return r.index.Save()
+}
+
+// Learn stores record in the index, merging it into an existing record for the same command.
+// The description and the query that led here are embedded as separate entry points.
+func (r *Retriever) Learn(ctx context.Context, record Record, query string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reembed(ctx); err != nil {
+		return err
+	}
+
+	recordIndex := r.index.findRecord(record.Command)
+	var existing Record
+	if recordIndex != -1 {
+		existing = r.index.Records[recordIndex]
+	}
+
+	// embed everything before touching the index, so a failure leaves it unchanged
+	var descriptionVector, queryVector []float32
+	var err error
+	if record.Description != "" && record.Description != existing.Description {
+		descriptionVector, err = r.embedder.Embed(ctx, record.Description)
+		if err != nil {
+			return fmt.Errorf("embed description: %w", err)
+		}
+	}
+	if query != "" && !slices.Contains(existing.UserQueries, query) {
+		queryVector, err = r.embedder.Embed(ctx, query)
+		if err != nil {
+			return fmt.Errorf("embed query: %w", err)
+		}
+	}
+
+	if recordIndex == -1 {
+		r.index.Records = append(r.index.Records, Record{Command: record.Command})
+		recordIndex = len(r.index.Records) - 1
+	}
+	updated := &r.index.Records[recordIndex]
+
+	if descriptionVector != nil {
+		// the newer description replaces the old one as an entry point
+		r.index.Embeddings = slices.DeleteFunc(r.index.Embeddings, func(e embedding) bool {
+			return e.Record == recordIndex && e.Kind == embeddingDescription
+		})
+		r.index.Embeddings = append(r.index.Embeddings, embedding{
+			Record: recordIndex,
+			Kind:   embeddingDescription,
+			Text:   record.Description,
+			Vector: descriptionVector,
+		})
+		updated.Description = record.Description
+	}
+
+	if queryVector != nil {
+		r.index.Embeddings = append(r.index.Embeddings, embedding{
+			Record: recordIndex,
+			Kind:   embeddingUserQuery,
+			Text:   query,
+			Vector: queryVector,
+		})
+		updated.UserQueries = append(updated.UserQueries, query)
+	}
+
+	if record.HelpSummary != "" {
+		updated.HelpSummary = record.HelpSummary
+	}
+	if record.Example != "" {
+		updated.Example = record.Example
+	}
+
+	r.index.EmbeddingModel = r.embedder.ModelName()
+
+	return r.index.Save()
+}
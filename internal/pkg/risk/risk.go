@@ -0,0 +1,356 @@
+// Package risk flags generated commands that could do damage, so they get a closer look before running.
+// Commands are parsed rather than pattern matched, so quoting, pipelines and wrappers like sudo are seen through.
+package risk
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/azvaliev/cmd/internal/pkg/shell"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+type Level int
+
+const (
+	LOW Level = iota
+	// shown in the confirm view, but runs on a single enter
+	MEDIUM
+	// needs a typed confirmation to run
+	HIGH
+)
+
+func (l Level) String() string {
+	switch l {
+	case MEDIUM:
+		return "medium"
+	case HIGH:
+		return "high"
+	}
+	return "low"
+}
+
+type Assessment struct {
+	Level   Level
+	Reasons []string
+	// the deny pattern the command matched, it must not run at all
+	DeniedBy string
+}
+
+// Denied reports whether the command matched a deny pattern
+func (a Assessment) Denied() bool {
+	return a.DeniedBy != ""
+}
+
+func (a *Assessment) flag(level Level, reason string) {
+	a.Level = max(a.Level, level)
+	if !slices.Contains(a.Reasons, reason) {
+		a.Reasons = append(a.Reasons, reason)
+	}
+}
+
+// Analyzer assesses commands, refusing any that match its deny patterns.
+// The zero value has no deny patterns.
+type Analyzer struct {
+	deny []*regexp.Regexp
+}
+
+// NewAnalyzer compiles deny, regular expressions matched against the whole command
+func NewAnalyzer(deny []string) (*Analyzer, error) {
+	analyzer := &Analyzer{}
+	for _, pattern := range deny {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		analyzer.deny = append(analyzer.deny, re)
+	}
+	return analyzer, nil
+}
+
+func (a *Analyzer) Analyze(command string) Assessment {
+	var assessment Assessment
+
+	for _, re := range a.deny {
+		if re.MatchString(command) {
+			assessment.DeniedBy = re.String()
+			assessment.flag(HIGH, fmt.Sprintf("matches the deny pattern %q", re.String()))
+			return assessment
+		}
+	}
+
+	file, err := shell.Parse(command)
+	if err != nil {
+		// can't tell what it does, and it likely won't run as intended either
+		assessment.flag(MEDIUM, "couldn't be parsed as a shell command")
+		return assessment
+	}
+
+	check(&assessment, file)
+
+	return assessment
+}
+
+func check(assessment *Assessment, file *syntax.File) {
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.CallExpr:
+			args := shell.Words(node.Args)
+			checkCall(assessment, args)
+			// the same words, from the command the wrappers run
+			checkInterpreterArgs(assessment, node.Args[len(args)-len(shell.UnwrapAll(args)):])
+		case *syntax.Stmt:
+			for _, redirect := range node.Redirs {
+				checkRedirect(assessment, redirect)
+			}
+		case *syntax.BinaryCmd:
+			if (node.Op == syntax.Pipe || node.Op == syntax.PipeAll) && downloads(node.X) && isInterpreter(firstCall(node.Y)) {
+				assessment.flag(HIGH, "runs a script downloaded from the internet")
+			}
+		}
+		return true
+	})
+}
+
+var (
+	shells       = []string{"sh", "bash", "zsh", "fish", "dash", "ksh"}
+	interpreters = append(slices.Clone(shells), "python", "python3", "perl", "ruby", "node")
+	downloaders  = []string{"curl", "wget", "fetch"}
+	// where writes can break the system
+	systemDirs  = []string{"/etc", "/boot", "/usr", "/bin", "/sbin", "/lib", "/lib64", "/System"}
+	diskDevices = []string{"/dev/sd", "/dev/hd", "/dev/vd", "/dev/nvme", "/dev/disk", "/dev/rdisk", "/dev/mmcblk"}
+	// deleting any of these takes everything with it
+	sweepingTargets = []string{"/", "/*", "~", "~/", "~/*", "$HOME", "$HOME/", "*", ".", "..", "./*"}
+)
+
+func checkCall(assessment *Assessment, args []string) {
+	if len(args) == 0 {
+		return
+	}
+
+	name := path.Base(args[0])
+	flags, operands := splitFlags(args[1:])
+
+	switch {
+	case shell.IsWrapper(name):
+		if name == "sudo" || name == "doas" {
+			assessment.flag(MEDIUM, "runs as root")
+		}
+		// the wrapped command is checked as if it were run directly
+		checkCall(assessment, shell.Unwrap(args))
+
+	case name == "rm":
+		recursive := hasFlag(flags, 'r', "--recursive") || hasFlag(flags, 'R', "")
+		force := hasFlag(flags, 'f', "--force")
+		for _, operand := range operands {
+			if slices.Contains(sweepingTargets, operand) {
+				assessment.flag(HIGH, "deletes "+operand)
+			}
+		}
+		switch {
+		case recursive && force:
+			assessment.flag(HIGH, "deletes recursively without asking")
+		case recursive:
+			assessment.flag(MEDIUM, "deletes directories recursively")
+		default:
+			assessment.flag(MEDIUM, "deletes files")
+		}
+		checkSystemWrites(assessment, operands)
+
+	case name == "find":
+		checkFind(assessment, args[1:])
+
+	case name == "dd":
+		for _, arg := range args[1:] {
+			if target, ok := strings.CutPrefix(arg, "of="); ok {
+				assessment.flag(MEDIUM, "writes raw data to "+target)
+				checkSystemWrites(assessment, []string{target})
+			}
+		}
+
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		if hasFlag(flags, 'R', "--recursive") {
+			assessment.flag(MEDIUM, "changes ownership or permissions recursively")
+		}
+		if name == "chmod" && len(operands) > 0 && strings.HasSuffix(operands[0], "777") {
+			assessment.flag(MEDIUM, "makes files writable by everyone")
+		}
+		checkSystemWrites(assessment, operands)
+
+	case strings.HasPrefix(name, "mkfs") || slices.Contains([]string{"wipefs", "fdisk", "sfdisk", "gdisk", "parted", "shred"}, name):
+		assessment.flag(HIGH, "erases or repartitions a disk")
+	case name == "diskutil" && len(operands) > 0 && strings.HasPrefix(strings.ToLower(operands[0]), "erase"):
+		assessment.flag(HIGH, "erases a disk")
+
+	case name == "git":
+		// global options come before the subcommand, some with a value, e.g. git -C repo push
+		gitArgs := args[1:]
+		for len(gitArgs) > 1 && (gitArgs[0] == "-C" || gitArgs[0] == "-c") {
+			gitArgs = gitArgs[2:]
+		}
+		flags, operands := splitFlags(gitArgs)
+		if len(operands) > 0 {
+			checkGit(assessment, operands[0], flags, operands[1:])
+		}
+
+	case slices.Contains([]string{"shutdown", "reboot", "halt", "poweroff"}, name):
+		assessment.flag(HIGH, "shuts down or restarts the machine")
+	case slices.Contains([]string{"kill", "killall", "pkill"}, name):
+		assessment.flag(MEDIUM, "stops processes")
+
+	case slices.Contains([]string{"cp", "mv", "ln", "install", "rsync"}, name) && len(operands) > 0:
+		// only the destination is written to
+		checkSystemWrites(assessment, operands[len(operands)-1:])
+	case name == "tee" || name == "truncate":
+		checkSystemWrites(assessment, operands)
+	case name == "sed" && hasFlag(flags, 'i', "--in-place"):
+		checkSystemWrites(assessment, operands)
+	}
+}
+
+// checkInterpreterArgs flags an interpreter given a download as an argument, e.g. sh -c "$(curl ...)"
+// or bash <(curl ...). A script passed to a shell with -c is checked like any other command.
+func checkInterpreterArgs(assessment *Assessment, words []*syntax.Word) {
+	if len(words) == 0 || !slices.Contains(interpreters, path.Base(shell.Word(words[0]))) {
+		return
+	}
+
+	for _, word := range words[1:] {
+		if downloads(word) {
+			assessment.flag(HIGH, "runs a script downloaded from the internet")
+		}
+	}
+
+	if !slices.Contains(shells, path.Base(shell.Word(words[0]))) {
+		return
+	}
+	// -c can be grouped with other flags, e.g. bash -lc
+	for i := 1; i < len(words)-1; i++ {
+		if arg := shell.Word(words[i]); strings.HasPrefix(arg, "-") && hasFlag([]string{arg}, 'c', "") {
+			// a script that doesn't parse won't run either
+			if file, err := shell.Parse(shell.Word(words[i+1])); err == nil {
+				check(assessment, file)
+			}
+			return
+		}
+	}
+}
+
+// checkFind checks the commands find runs for each file it finds, and whether it deletes them
+func checkFind(assessment *Assessment, args []string) {
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-delete":
+			assessment.flag(MEDIUM, "deletes the files it finds")
+		case "-exec", "-execdir", "-ok", "-okdir":
+			// the command ends at ; (once per file) or + (once for all of them), escaped from the shell
+			end := i + 1
+			for end < len(args) && !slices.Contains([]string{";", `\;`, "+"}, args[end]) {
+				end++
+			}
+			checkCall(assessment, args[i+1:end])
+			i = end
+		}
+	}
+}
+
+func checkGit(assessment *Assessment, subcommand string, flags, operands []string) {
+	switch subcommand {
+	case "push":
+		force := hasFlag(flags, 'f', "--force") || slices.ContainsFunc(flags, func(flag string) bool {
+			return strings.HasPrefix(flag, "--force-with-lease")
+		})
+		if force || slices.ContainsFunc(operands, func(refspec string) bool { return strings.HasPrefix(refspec, "+") }) {
+			assessment.flag(HIGH, "force pushes, overwriting history on the remote")
+		}
+	case "reset":
+		if slices.Contains(flags, "--hard") {
+			assessment.flag(MEDIUM, "discards uncommitted changes")
+		}
+	case "clean":
+		if hasFlag(flags, 'f', "--force") {
+			assessment.flag(MEDIUM, "deletes untracked files")
+		}
+	}
+}
+
+func checkRedirect(assessment *Assessment, redirect *syntax.Redirect) {
+	switch redirect.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+		checkSystemWrites(assessment, []string{shell.Word(redirect.Word)})
+	}
+}
+
+func checkSystemWrites(assessment *Assessment, targets []string) {
+	for _, target := range targets {
+		for _, device := range diskDevices {
+			if strings.HasPrefix(target, device) {
+				assessment.flag(HIGH, "writes to the disk "+target)
+			}
+		}
+		for _, dir := range systemDirs {
+			if target == dir || strings.HasPrefix(target, dir+"/") {
+				assessment.flag(HIGH, "modifies system files in "+dir)
+			}
+		}
+	}
+}
+
+// splitFlags separates flags from operands, treating everything after "--" as an operand
+func splitFlags(args []string) (flags, operands []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, append(operands, args[i+1:]...)
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			flags = append(flags, arg)
+		} else {
+			operands = append(operands, arg)
+		}
+	}
+	return flags, operands
+}
+
+// hasFlag reports whether flags include short (alone or combined, e.g. -rf) or long
+func hasFlag(flags []string, short rune, long string) bool {
+	for _, flag := range flags {
+		if long != "" && (flag == long || strings.HasPrefix(flag, long+"=")) {
+			return true
+		}
+		if !strings.HasPrefix(flag, "--") && strings.ContainsRune(flag[1:], short) {
+			return true
+		}
+	}
+	return false
+}
+
+// downloads reports whether cmd contains a call to curl, wget or the like
+func downloads(cmd syntax.Node) bool {
+	found := false
+	syntax.Walk(cmd, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 && slices.Contains(downloaders, path.Base(shell.Word(call.Args[0]))) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func isInterpreter(args []string) bool {
+	args = shell.UnwrapAll(args)
+	return len(args) > 0 && slices.Contains(interpreters, path.Base(args[0]))
+}
+
+func firstCall(cmd syntax.Node) []string {
+	var args []string
+	syntax.Walk(cmd, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && args == nil {
+			args = shell.Words(call.Args)
+		}
+		return args == nil
+	})
+	return args
+}
@@ -0,0 +1,114 @@
+package risk
+
+import (
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		command string
+		want    Level
+	}{
+		{"ls -la", LOW},
+		{"echo 'rm -rf /'", LOW},
+		{"git status && git log --oneline", LOW},
+		{"rm notes.txt", MEDIUM},
+		{"rm -r build", MEDIUM},
+		{"rm -rf build", HIGH},
+		{"rm -r -f build", HIGH},
+		{"rm /", HIGH},
+		{`rm -r "$HOME"`, HIGH},
+		{"sudo apt update", MEDIUM},
+		{"sudo rm -rf /", HIGH},
+		{"sudo -n rm -rf /", HIGH},
+		{"sudo -u root rm -rf /", HIGH},
+		{"sudo -E -u root env FOO=1 rm -rf /", HIGH},
+		{"doas -u root rm -rf /", HIGH},
+		{"nice -n 10 rm -rf build", HIGH},
+		{"xargs -n 1 rm -rf < dirs.txt", HIGH},
+		{"find . -name '*.log' | xargs -I {} rm -rf {}", HIGH},
+		{"/usr/bin/time -f %e rm -rf build", HIGH},
+		{"find . -name '*.tmp' -delete", MEDIUM},
+		{"find . -name node_modules -exec rm -rf {} +", HIGH},
+		{`find / -name '*.bak' -exec rm {} \;`, MEDIUM},
+		{"find . -type d -execdir rm -rf {} ';'", HIGH},
+		{"find . -name '*.go' -exec grep -l TODO {} +", LOW},
+		{"find . -name '*.conf' -exec sed -i s/a/b/ /etc/hosts \\;", HIGH},
+		{"dd if=image.iso of=/dev/sda", HIGH},
+		{"dd if=/dev/zero of=out.bin bs=1M count=1", MEDIUM},
+		{"echo 'nameserver 1.1.1.1' > /etc/resolv.conf", HIGH},
+		{"echo hi > notes.txt", LOW},
+		{"chmod -R 755 src", MEDIUM},
+		{"chmod 777 script.sh", MEDIUM},
+		{"mkfs.ext4 /dev/sdb1", HIGH},
+		{"git push --force origin main", HIGH},
+		{"git push origin +main", HIGH},
+		{"git -C repo push -f", HIGH},
+		{"git reset --hard HEAD~1", MEDIUM},
+		{"curl -fsSL https://example.com/install.sh | sh", HIGH},
+		{"curl -fsSL https://example.com/install.sh | sudo bash", HIGH},
+		{`sh -c "$(curl -fsSL https://example.com/install.sh)"`, HIGH},
+		{"curl -fsSL https://example.com/data.json | jq .", LOW},
+		{"bash <(curl -fsSL https://example.com/install.sh)", HIGH},
+		{`sudo sh -c "$(fetch -o - https://example.com/install.sh)"`, HIGH},
+		{"bash -c 'curl -fsSL https://example.com/install.sh | sh'", HIGH},
+		{`bash -lc "rm -rf /"`, HIGH},
+		{"bash -c 'echo hi'", LOW},
+		{"bash -c", LOW},
+		{"sh", LOW},
+		{"python3 parse_curl_log.py", LOW},
+		{"bash wget_mirror.sh", LOW},
+		{"reboot", HIGH},
+		{"command reboot", HIGH},
+		{"command -v reboot", LOW},
+		{"pkill node", MEDIUM},
+		{"cp config.toml /etc/app/", HIGH},
+		{"echo 'unbalanced", MEDIUM},
+	}
+
+	analyzer := &Analyzer{}
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			got := analyzer.Analyze(test.command)
+			if got.Level != test.want {
+				t.Errorf("Analyze(%q).Level = %s, want %s (reasons: %v)", test.command, got.Level, test.want, got.Reasons)
+			}
+			if test.want > LOW && len(got.Reasons) == 0 {
+				t.Errorf("Analyze(%q) gave no reasons", test.command)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDeny(t *testing.T) {
+	analyzer, err := NewAnalyzer([]string{`terraform\s+destroy`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command    string
+		wantDenied bool
+	}{
+		{"terraform destroy -auto-approve", true},
+		{"terraform plan", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			got := analyzer.Analyze(test.command)
+			if got.Denied() != test.wantDenied {
+				t.Errorf("Analyze(%q).Denied() = %v, want %v", test.command, got.Denied(), test.wantDenied)
+			}
+			if got.Denied() && got.Level != HIGH {
+				t.Errorf("Analyze(%q).Level = %s, want high", test.command, got.Level)
+			}
+		})
+	}
+}
+
+func TestNewAnalyzerInvalidPattern(t *testing.T) {
+	if _, err := NewAnalyzer([]string{"("}); err == nil {
+		t.Error("NewAnalyzer accepted an invalid pattern")
+	}
+}
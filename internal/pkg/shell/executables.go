@@ -0,0 +1,132 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/xdg"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// an interactive shell has to load the user's rc files to list their aliases
+const aliasTimeout = 2 * time.Second
+
+// bash and zsh builtins, which never show up in $PATH
+var builtins = []string{
+	".", ":", "[", "alias", "autoload", "bg", "bind", "bindkey", "break", "builtin", "caller", "cd",
+	"command", "compgen", "complete", "continue", "declare", "dirs", "disown", "echo", "emulate",
+	"enable", "eval", "exec", "exit", "export", "false", "fc", "fg", "functions", "getopts", "hash",
+	"help", "history", "jobs", "kill", "let", "local", "logout", "mapfile", "noglob", "popd", "print",
+	"printf", "pushd", "pwd", "read", "readarray", "readonly", "rehash", "return", "set", "setopt",
+	"shift", "shopt", "source", "suspend", "test", "times", "trap", "true", "type", "typeset",
+	"ulimit", "umask", "unalias", "unset", "unsetopt", "wait", "whence", "where", "which", "zmodload",
+}
+
+// Executables lists the commands file runs, each once, in order. Wrappers like sudo
+// are seen through, and names only known once expanded (e.g. $EDITOR) are left out,
+// as are functions file defines itself.
+func Executables(file *syntax.File) []string {
+	var functions, names []string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.FuncDecl:
+			functions = append(functions, node.Name.Value)
+		case *syntax.CallExpr:
+			args := UnwrapAll(Words(node.Args))
+			if len(args) == 0 || strings.ContainsAny(args[0], "$`*?") || slices.Contains(names, args[0]) {
+				return true
+			}
+			names = append(names, args[0])
+		}
+		return true
+	})
+
+	return slices.DeleteFunc(names, func(name string) bool {
+		return slices.Contains(functions, name)
+	})
+}
+
+// Missing returns the names that aren't a builtin or in $PATH
+func Missing(names []string) []string {
+	var missing []string
+	for _, name := range names {
+		if !isInstalled(name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// WithoutAliases drops the names that are aliases in the user's shell. Listing them means
+// starting an interactive shell, which runs the user's rc files, so it's only done on request.
+func WithoutAliases(names []string) []string {
+	if len(names) == 0 {
+		return names
+	}
+
+	aliases := loadAliases()
+	return slices.DeleteFunc(names, func(name string) bool {
+		return slices.Contains(aliases, name)
+	})
+}
+
+func isInstalled(name string) bool {
+	if slices.Contains(builtins, name) {
+		return true
+	}
+
+	// a path, e.g. ./build.sh, rather than a name to look up
+	if strings.Contains(name, "/") {
+		info, err := os.Stat(xdg.ExpandHome(name))
+		return err == nil && !info.IsDir()
+	}
+
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// loadAliases lists the aliases defined in the user's shell, once per process.
+// No aliases is the worst case, the command is then flagged as missing when it isn't.
+var loadAliases = sync.OnceValue(func() []string {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), aliasTimeout)
+	defer cancel()
+
+	proc := exec.CommandContext(ctx, shell, "-ic", "alias")
+	// without a terminal of its own, an interactive shell takes over cmd's, leaving cmd unable to read keys
+	proc.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	output, _ := proc.Output()
+	return parseAliases(output)
+})
+
+// parseAliases reads the names from `alias` output, which is `alias name='value'`
+// in bash, `name=value` in zsh and `alias name value` in fish
+func parseAliases(output []byte) []string {
+	var aliases []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line, prefixed := strings.CutPrefix(scanner.Text(), "alias ")
+		end := strings.IndexByte(line, '=')
+		if prefixed {
+			end = strings.IndexAny(line, "= ")
+		}
+		// rc files can print anything, e.g. a greeting
+		if end <= 0 || strings.ContainsAny(line[:end], " \t") {
+			continue
+		}
+		aliases = append(aliases, line[:end])
+	}
+	return aliases
+}
@@ -0,0 +1,59 @@
+package shell
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseAliases(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{"bash", "alias ll='ls -l'\nalias gs='git status'\n", []string{"ll", "gs"}},
+		{"zsh", "ll='ls -l'\ngs='git status'\n", []string{"ll", "gs"}},
+		{"fish", "alias ll 'ls -l'\nalias gs 'git status'\n", []string{"ll", "gs"}},
+		{"greeting from an rc file", "Welcome back!\nalias ll='ls -l'\n", []string{"ll"}},
+		{"empty", "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseAliases([]byte(test.output))
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("parseAliases() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecutables(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"ls -la", []string{"ls"}},
+		{"sudo -u root apt install jq | tee log", []string{"apt", "tee"}},
+		{"grep foo *.go | sort | uniq -c", []string{"grep", "sort", "uniq"}},
+		{"$EDITOR notes.txt", nil},
+		{"greet() { echo hi; }; greet", []string{"echo"}},
+		{"echo $(date +%F)", []string{"echo", "date"}},
+		{"command -v jq || sudo apt install jq", []string{"apt"}},
+		{"command -pV jq", nil},
+		{"command -p jq .", []string{"jq"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			file, err := Parse(test.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Executables(file)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("Executables(%q) = %q, want %q", test.command, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Package shell cleans up and parses generated commands. Small models wrap their
+// answers in markdown or trail off into prose however they're prompted, so what
+// they reply with is normalized before it's treated as a command.
+package shell
+
+import (
+	"errors"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var ErrEmpty = errors.New("empty command")
+
+// Normalize extracts the command from a model's reply: the contents of the first
+// code fence or `inline code`, without a leading "$ " prompt or any prose after a
+// blank line. Works on partial replies too, so a streaming command can be shown as it will end up.
+func Normalize(reply string) string {
+	command := strings.TrimSpace(reply)
+
+	if start := strings.Index(command, "```"); start != -1 {
+		command = command[start+3:]
+		// the language tag, e.g. ```bash
+		if newline := strings.IndexByte(command, '\n'); newline != -1 {
+			command = command[newline+1:]
+		} else {
+			command = ""
+		}
+		if end := strings.Index(command, "```"); end != -1 {
+			command = command[:end]
+		}
+		command = strings.TrimSpace(command)
+	}
+
+	command = cutExplanation(command)
+
+	command = unwrap(command, "`")
+
+	lines := strings.Split(command, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "$ ")
+	}
+	command = strings.Join(lines, "\n")
+
+	// a command quoted as a whole would run as a single word, e.g. "ls -la"
+	command = unwrap(command, `"`)
+	command = unwrap(command, "'")
+
+	return strings.TrimSpace(command)
+}
+
+// cutExplanation drops whatever follows a blank line, which is an explanation rather than part
+// of the command. Blank lines inside the command (a heredoc, a quoted string) are skipped by
+// cutting at the first one the command parses up to.
+func cutExplanation(command string) string {
+	first := strings.Index(command, "\n\n")
+	if first == -1 {
+		return command
+	}
+
+	for blank := first; blank != -1; {
+		if _, err := syntax.NewParser().Parse(strings.NewReader(command[:blank]), ""); err == nil {
+			return strings.TrimSpace(command[:blank])
+		}
+		next := strings.Index(command[blank+2:], "\n\n")
+		if next == -1 {
+			break
+		}
+		blank += 2 + next
+	}
+
+	// a heredoc with blank lines and nothing after it
+	if _, err := syntax.NewParser().Parse(strings.NewReader(command), ""); err == nil {
+		return command
+	}
+	// e.g. an unclosed quote, which the model is asked to fix, or a heredoc that's still streaming
+	return strings.TrimSpace(command[:first])
+}
+
+// unwrap removes quote from around s, as long as it doesn't appear anywhere else
+func unwrap(s, quote string) string {
+	if len(s) > 2*len(quote) && strings.HasPrefix(s, quote) && strings.HasSuffix(s, quote) {
+		inner := s[len(quote) : len(s)-len(quote)]
+		if !strings.Contains(inner, quote) {
+			return inner
+		}
+	}
+	return s
+}
+
+// Parse reads command as bash, failing on syntax errors like unbalanced quotes
+func Parse(command string) (*syntax.File, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Stmts) == 0 {
+		return nil, ErrEmpty
+	}
+	return file, nil
+}
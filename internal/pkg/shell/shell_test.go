@@ -0,0 +1,92 @@
+package shell
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"plain", "ls -la", "ls -la"},
+		{"surrounding whitespace", "\n  ls -la  \n", "ls -la"},
+		{"fenced", "```bash\nls -la\n```", "ls -la"},
+		{"fenced with prose around it", "Here you go:\n```sh\n$ du -sh *\n```\n\nThis shows sizes.", "du -sh *"},
+		{"fenced without a language", "```\ngit status\n```", "git status"},
+		{"unclosed fence while streaming", "```bash\nfind . -na", "find . -na"},
+		{"fence tag only so far", "```bash", ""},
+		{"inline code", "`ls -la`", "ls -la"},
+		{"prompt prefix", "$ echo hi", "echo hi"},
+		{"prompt prefix on each line", "$ cd src &&\n$ make", "cd src &&\nmake"},
+		{"explanation after a blank line", "tar -xzf backup.tar.gz\n\nThis extracts the archive.", "tar -xzf backup.tar.gz"},
+		{"quoted as a whole", `"ls -la"`, "ls -la"},
+		{"single quoted as a whole", "'ls -la'", "ls -la"},
+		{"quotes that belong to the command", `echo "a" "b"`, `echo "a" "b"`},
+		{"trailing backslash", "docker run \\\n  --rm alpine", "docker run \\\n  --rm alpine"},
+		{
+			"heredoc with a blank line",
+			"cat <<EOF > notes.txt\nfirst\n\nsecond\nEOF",
+			"cat <<EOF > notes.txt\nfirst\n\nsecond\nEOF",
+		},
+		{
+			"heredoc with a blank line and an explanation",
+			"cat <<'EOF' > notes.txt\nfirst\n\nsecond\nEOF\n\nThis writes two paragraphs.",
+			"cat <<'EOF' > notes.txt\nfirst\n\nsecond\nEOF",
+		},
+		{
+			"fenced heredoc with a blank line",
+			"```bash\ncat <<EOF\na\n\nb\nEOF\n```",
+			"cat <<EOF\na\n\nb\nEOF",
+		},
+		{
+			"quoted string with a blank line",
+			"git commit -m \"Fix parser\n\nHandles heredocs\"",
+			"git commit -m \"Fix parser\n\nHandles heredocs\"",
+		},
+		{"unclosed quote with an explanation", "echo \"oops\n\nThis prints oops.", `echo "oops`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Normalize(test.reply); got != test.want {
+				t.Errorf("Normalize(%q) = %q, want %q", test.reply, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		command string
+		wantErr bool
+	}{
+		{"ls -la", false},
+		{"ls | grep foo && echo done", false},
+		{"cat <<EOF\nhello\n\nworld\nEOF", false},
+		{"cat <<EOF\nhello", true},
+		{"docker run \\\n  --rm alpine", false},
+		{"echo \"unbalanced", true},
+		{"if true; then echo yes", true},
+		{"for f in *.txt; do echo \"$f\"; done", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.command, func(t *testing.T) {
+			_, err := Parse(test.command)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Parse(%q) error = %v, want error %v", test.command, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, command := range []string{"", "  \n", "# just a comment"} {
+		if _, err := Parse(command); !errors.Is(err, ErrEmpty) {
+			t.Errorf("Parse(%q) error = %v, want ErrEmpty", command, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Commands which run the rest of their arguments as a command, with their flags which
+// take a value, so the value isn't mistaken for the command. Flags differ between wrappers,
+// e.g. sudo -n is non-interactive, while nice -n takes the niceness.
+var wrappers = map[string][]string{
+	"sudo":    {"-u", "-g", "-p", "-C", "-h", "-U", "-D", "-r", "-t", "-T"},
+	"doas":    {"-u", "-C"},
+	"env":     {"-u", "-C", "--unset", "--chdir"},
+	"nohup":   nil,
+	"time":    {"-f", "-o", "--format", "--output"},
+	"nice":    {"-n", "--adjustment"},
+	"exec":    {"-a"},
+	"command": nil,
+	"xargs":   {"-n", "-I", "-L", "-P", "-d", "-E", "-s", "-a", "--max-args", "--max-procs", "--delimiter", "--arg-file"},
+	"watch":   {"-n", "--interval"},
+}
+
+// IsWrapper reports whether name runs its arguments as a command, like sudo or xargs
+func IsWrapper(name string) bool {
+	_, ok := wrappers[name]
+	return ok
+}
+
+// Unwrap drops the wrapper in args[0], along with its own flags and variable assignments,
+// leaving the command it runs. It's empty when nothing is run, e.g. command -v only looks the command up.
+func Unwrap(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	name := path.Base(args[0])
+	flagsWithValue := wrappers[name]
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case slices.Contains(flagsWithValue, arg):
+			i++
+		case name == "command" && isLookupFlag(arg):
+			return nil
+		case strings.HasPrefix(arg, "-"), strings.Contains(arg, "="):
+		default:
+			return args[i:]
+		}
+	}
+	return nil
+}
+
+// isLookupFlag reports whether arg asks command to describe the command instead of running it,
+// including when it's grouped with -p (e.g. -pv)
+func isLookupFlag(arg string) bool {
+	return strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "vV")
+}
+
+// UnwrapAll strips wrappers until the command they run is reached, e.g. sudo env bash → bash
+func UnwrapAll(args []string) []string {
+	for len(args) > 0 && IsWrapper(path.Base(args[0])) {
+		args = Unwrap(args)
+	}
+	return args
+}
+
+func Words(ws []*syntax.Word) []string {
+	out := make([]string, len(ws))
+	for i, w := range ws {
+		out[i] = Word(w)
+	}
+	return out
+}
+
+// Word flattens w as the shell would see it before expansion, keeping quoted text as is.
+// Expansions are printed in their source form (e.g. $HOME), since their values aren't known yet.
+func Word(w *syntax.Word) string {
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		writeWordPart(&sb, part)
+	}
+	return sb.String()
+}
+
+func writeWordPart(sb *strings.Builder, part syntax.WordPart) {
+	switch part := part.(type) {
+	case *syntax.Lit:
+		sb.WriteString(part.Value)
+	case *syntax.SglQuoted:
+		sb.WriteString(part.Value)
+	case *syntax.DblQuoted:
+		for _, inner := range part.Parts {
+			writeWordPart(sb, inner)
+		}
+	default:
+		syntax.NewPrinter().Print(sb, part)
+	}
+}
@@ -0,0 +1,87 @@
+// Package sysinfo describes the system cmd is running on, so commands fit it.
+package sysinfo
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+type PackageManager string
+
+const (
+	PACKAGE_MANAGER_APT    PackageManager = "apt"
+	PACKAGE_MANAGER_DNF    PackageManager = "dnf"
+	PACKAGE_MANAGER_PACMAN PackageManager = "pacman"
+	PACKAGE_MANAGER_BREW   PackageManager = "brew"
+	PACKAGE_MANAGER_NIX    PackageManager = "nix"
+)
+
+// in order of preference, the system's own package manager before one installed on top of it
+var packageManagers = []PackageManager{
+	PACKAGE_MANAGER_APT,
+	PACKAGE_MANAGER_DNF,
+	PACKAGE_MANAGER_PACMAN,
+	PACKAGE_MANAGER_BREW,
+	PACKAGE_MANAGER_NIX,
+}
+
+// packages where the name differs from the command it provides, by package manager
+var packageNames = map[string]map[PackageManager]string{
+	"rg":    {PACKAGE_MANAGER_APT: "ripgrep", PACKAGE_MANAGER_DNF: "ripgrep", PACKAGE_MANAGER_PACMAN: "ripgrep", PACKAGE_MANAGER_BREW: "ripgrep", PACKAGE_MANAGER_NIX: "ripgrep"},
+	"ag":    {PACKAGE_MANAGER_APT: "silversearcher-ag", PACKAGE_MANAGER_DNF: "the_silver_searcher", PACKAGE_MANAGER_PACMAN: "the_silver_searcher", PACKAGE_MANAGER_BREW: "the_silver_searcher", PACKAGE_MANAGER_NIX: "silver-searcher"},
+	"http":  {PACKAGE_MANAGER_APT: "httpie", PACKAGE_MANAGER_DNF: "httpie", PACKAGE_MANAGER_PACMAN: "httpie", PACKAGE_MANAGER_BREW: "httpie", PACKAGE_MANAGER_NIX: "httpie"},
+	"delta": {PACKAGE_MANAGER_APT: "git-delta", PACKAGE_MANAGER_DNF: "git-delta", PACKAGE_MANAGER_PACMAN: "git-delta", PACKAGE_MANAGER_BREW: "git-delta", PACKAGE_MANAGER_NIX: "delta"},
+	"nvim":  {PACKAGE_MANAGER_APT: "neovim", PACKAGE_MANAGER_DNF: "neovim", PACKAGE_MANAGER_PACMAN: "neovim", PACKAGE_MANAGER_BREW: "neovim", PACKAGE_MANAGER_NIX: "neovim"},
+	"7z":    {PACKAGE_MANAGER_APT: "p7zip-full", PACKAGE_MANAGER_DNF: "p7zip", PACKAGE_MANAGER_PACMAN: "p7zip", PACKAGE_MANAGER_BREW: "p7zip", PACKAGE_MANAGER_NIX: "p7zip"},
+}
+
+// DetectPackageManager returns the package manager used to install software here,
+// or "" when there's none cmd knows about
+func DetectPackageManager() PackageManager {
+	// on macOS there's no system package manager to prefer
+	if runtime.GOOS == "darwin" {
+		if _, err := exec.LookPath("brew"); err == nil {
+			return PACKAGE_MANAGER_BREW
+		}
+	}
+
+	for _, manager := range packageManagers {
+		binary := string(manager)
+		if manager == PACKAGE_MANAGER_APT {
+			binary = "apt-get"
+		}
+		if _, err := exec.LookPath(binary); err == nil {
+			return manager
+		}
+	}
+	return ""
+}
+
+// InstallCommand installs the packages providing commands, guessing the
+// package is named after the command unless it's known to differ
+func (p PackageManager) InstallCommand(commands []string) string {
+	packages := make([]string, len(commands))
+	for i, command := range commands {
+		packages[i] = command
+		if name, ok := packageNames[command][p]; ok {
+			packages[i] = name
+		}
+	}
+
+	switch p {
+	case PACKAGE_MANAGER_APT, PACKAGE_MANAGER_DNF:
+		return fmt.Sprintf("sudo %s install -y %s", p, strings.Join(packages, " "))
+	case PACKAGE_MANAGER_PACMAN:
+		return "sudo pacman -S --noconfirm " + strings.Join(packages, " ")
+	case PACKAGE_MANAGER_BREW:
+		return "brew install " + strings.Join(packages, " ")
+	case PACKAGE_MANAGER_NIX:
+		for i, name := range packages {
+			packages[i] = "nixpkgs#" + name
+		}
+		return "nix profile install " + strings.Join(packages, " ")
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/xdg"
+)
+
+const CACHE_FILE_NAME = "sysinfo.json"
+
+// Collecting runs a handful of commands, which is worth skipping on every run,
+// but installed tools do change now and then
+const CACHE_TTL = 24 * time.Hour
+
+// how long any one probe (e.g. `bash --version`) gets
+const probeTimeout = 2 * time.Second
+
+// Flavors of the core utilities, whose flags differ (e.g. sed -i, find -printf)
+const (
+	COREUTILS_GNU     = "GNU"
+	COREUTILS_BSD     = "BSD"
+	COREUTILS_BUSYBOX = "BusyBox"
+)
+
+// CLIs worth telling the model about, since it can't otherwise know whether to reach for them
+var notableTools = []string{
+	"git", "gh", "docker", "podman", "kubectl", "helm", "terraform", "aws", "gcloud", "az",
+	"rg", "fd", "fdfind", "fzf", "bat", "eza", "jq", "yq", "ncdu", "htop", "btop", "tree",
+	"curl", "wget", "rsync", "tmux", "ffmpeg", "magick", "convert", "7z", "zip", "unzip",
+	"python3", "node", "go", "cargo", "make", "sqlite3", "psql", "mysql", "redis-cli",
+}
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+type Info struct {
+	// runtime.GOOS, e.g. "linux" or "darwin"
+	OS string `json:"os"`
+	// e.g. "Ubuntu 24.04 LTS" or "macOS 14.5"
+	Distro string `json:"distro"`
+	// e.g. "Linux 6.8.0-45-generic"
+	Kernel string `json:"kernel"`
+	Arch   string `json:"arch"`
+	// COREUTILS_*, empty when it couldn't be told
+	Coreutils      string         `json:"coreutils"`
+	Shell          string         `json:"shell"`
+	ShellVersion   string         `json:"shell_version"`
+	PackageManager PackageManager `json:"package_manager"`
+	// installed notableTools
+	Tools []string `json:"tools"`
+}
+
+// cached is the cache file, along with what the info was collected for
+type cached struct {
+	Time  time.Time `json:"time"`
+	Shell string    `json:"shell"`
+	Path  string    `json:"path"`
+	Info  Info      `json:"info"`
+}
+
+// Load returns the cached info, collecting it again when it's older than CACHE_TTL
+// or $SHELL or $PATH changed since. Failing to read or write the cache isn't an error,
+// it just means collecting every time.
+func Load(ctx context.Context) Info {
+	path := filepath.Join(xdg.CacheDir(), CACHE_FILE_NAME)
+
+	if data, err := os.ReadFile(path); err == nil {
+		var cache cached
+		if json.Unmarshal(data, &cache) == nil &&
+			time.Since(cache.Time) < CACHE_TTL &&
+			cache.Shell == os.Getenv("SHELL") &&
+			cache.Path == os.Getenv("PATH") {
+			return cache.Info
+		}
+	}
+
+	info := Collect(ctx)
+
+	data, err := json.Marshal(cached{
+		Time:  time.Now(),
+		Shell: os.Getenv("SHELL"),
+		Path:  os.Getenv("PATH"),
+		Info:  info,
+	})
+	if err == nil && os.MkdirAll(xdg.CacheDir(), 0o755) == nil {
+		os.WriteFile(path, data, 0o644)
+	}
+
+	return info
+}
+
+// Collect probes the system, leaving out whatever can't be found
+func Collect(ctx context.Context) Info {
+	info := Info{
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		Distro:         distro(ctx),
+		Kernel:         probe(ctx, "uname", "-sr"),
+		Coreutils:      coreutils(ctx),
+		PackageManager: DetectPackageManager(),
+	}
+
+	// commands are run with $SHELL -c
+	if shell := os.Getenv("SHELL"); shell != "" {
+		info.Shell = filepath.Base(shell)
+		info.ShellVersion = versionPattern.FindString(probe(ctx, shell, "--version"))
+	}
+
+	for _, tool := range notableTools {
+		if _, err := exec.LookPath(tool); err == nil {
+			info.Tools = append(info.Tools, tool)
+		}
+	}
+
+	return info
+}
+
+func distro(ctx context.Context) string {
+	switch runtime.GOOS {
+	case "darwin":
+		if version := probe(ctx, "sw_vers", "-productVersion"); version != "" {
+			return "macOS " + version
+		}
+		return "macOS"
+	case "linux":
+		data, err := os.ReadFile("/etc/os-release")
+		if err != nil {
+			return "Linux"
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if name, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+				return strings.Trim(name, `"'`)
+			}
+		}
+		return "Linux"
+	}
+	return runtime.GOOS
+}
+
+func coreutils(ctx context.Context) string {
+	// BSD ls rejects --version, but still says enough to tell them apart
+	version := probe(ctx, "ls", "--version")
+	switch {
+	case strings.Contains(version, "GNU"):
+		return COREUTILS_GNU
+	case strings.Contains(version, "BusyBox"):
+		return COREUTILS_BUSYBOX
+	case runtime.GOOS == "darwin" || strings.HasSuffix(runtime.GOOS, "bsd"):
+		return COREUTILS_BSD
+	}
+	return ""
+}
+
+// probe runs name, returning the first line it printed, even if it failed
+func probe(ctx context.Context, name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	output, _ := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return line
+}
+
+// Describe lists what's known about the system, for a system prompt
+func (i Info) Describe() string {
+	var sb strings.Builder
+
+	system := i.Distro
+	if i.Kernel != "" {
+		system += fmt.Sprintf(" (%s, %s)", i.Kernel, i.Arch)
+	}
+	fmt.Fprintf(&sb, "- OS: %s\n", system)
+
+	if i.Shell != "" {
+		fmt.Fprintf(&sb, "- Shell: %s\n", strings.TrimSpace(i.Shell+" "+i.ShellVersion))
+	}
+
+	switch i.Coreutils {
+	case COREUTILS_GNU:
+		sb.WriteString("- Core utilities: GNU\n")
+	case COREUTILS_BSD:
+		sb.WriteString("- Core utilities: BSD, so GNU-only flags won't work (e.g. use sed -i '')\n")
+	case COREUTILS_BUSYBOX:
+		sb.WriteString("- Core utilities: BusyBox, with only the common flags\n")
+	}
+
+	if i.PackageManager != "" {
+		fmt.Fprintf(&sb, "- Package manager: %s\n", i.PackageManager)
+	}
+	if len(i.Tools) > 0 {
+		fmt.Fprintf(&sb, "- Installed tools, besides the standard ones: %s\n", strings.Join(i.Tools, ", "))
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -55,6 +55,12 @@ func RenderExitCode(exitCode int) string {
 	return statusBoxStyle.Render(text)
 }
 
+var errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+func RenderError(err error) string {
+	return errorStyle.Render("Error: " + err.Error())
+}
+
 func RenderStatusBox(text string) string {
 	return statusBoxStyle.Render(text)
 }
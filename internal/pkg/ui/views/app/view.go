@@ -0,0 +1,313 @@
+// Package views is the root model, which moves between the generate and output
+// views so a command can be corrected and re-run within one program
+package views
+
+import (
+	"os"
+	"time"
+
+	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/history"
+	"github.com/azvaliev/cmd/internal/pkg/risk"
+	generateview "github.com/azvaliev/cmd/internal/pkg/ui/views/generate"
+	outputview "github.com/azvaliev/cmd/internal/pkg/ui/views/output"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type state int
+
+const (
+	stateGenerate state = iota
+	stateOutput
+	stateDone
+)
+
+// Run is a command that was run, and what it printed
+type Run struct {
+	Command string
+	Output  outputview.OutputResult
+}
+
+type AppResult struct {
+	// every command run, in order, for printing to the scrollback
+	Runs []Run
+	// the accepted command when it wasn't run (--no-run)
+	Command string
+	// the user backed out instead of accepting or finishing a command
+	Cancelled bool
+	Err       error
+	// the last failure to save to history, which isn't worth interrupting the user for
+	HistoryErr error
+}
+
+type AppModel struct {
+	state      state
+	noRun      bool
+	runOptions outputview.RunOptions
+
+	generate generateview.GenerateModel
+	output   outputview.OutputModel
+	agentCh  <-chan generateview.AgentResult
+	agent    *ai.CommandAgent
+	// nil for the generate view's default, see UseAnalyzer
+	analyzer *risk.Analyzer
+	// see CheckAliases
+	checkAliases bool
+	// the accepted command, kept for its history entry
+	generated generateview.GenerateResult
+	// set to skip straight to running a command, see NewHistoryAppModel
+	rerun *generateview.GenerateResult
+
+	// the output view is created mid-program, after the initial size message
+	windowSize tea.WindowSizeMsg
+
+	runs       []Run
+	command    string
+	cancelled  bool
+	err        error
+	historyErr error
+}
+
+// NewAppModel starts in the generate view, see generateview.NewGenerateModel.
+// With noRun, an accepted command ends the program instead of running it.
+func NewAppModel(agentCh <-chan generateview.AgentResult, prompt string, runOptions outputview.RunOptions, noRun bool) AppModel {
+	return AppModel{
+		state:      stateGenerate,
+		noRun:      noRun,
+		runOptions: runOptions,
+		generate:   generateview.NewGenerateModel(agentCh, prompt),
+		agentCh:    agentCh,
+	}
+}
+
+// NewHistoryAppModel picks up a command from history at the confirm screen,
+// or with rerun, runs it again in the directory it ran in, straight away when it's low risk
+func NewHistoryAppModel(agentCh <-chan generateview.AgentResult, entry history.Entry, runOptions outputview.RunOptions, noRun, rerun bool) AppModel {
+	if rerun {
+		runOptions.Dir = entry.Dir
+	}
+	m := NewAppModel(agentCh, entry.Prompt, runOptions, noRun)
+	m.generate = generateview.NewConfirmModel(agentCh, entry.Prompt, entry.Command, entry.Explanation)
+	if cwd, _ := os.Getwd(); runOptions.Dir != "" && runOptions.Dir != cwd {
+		m.generate.RunIn(runOptions.Dir)
+	}
+	// assessed even without UseAnalyzer, so a config that didn't load can't skip the confirm screen
+	if rerun && canRerun(&risk.Analyzer{}, entry.Command) {
+		m.rerun = &generateview.GenerateResult{
+			Prompt:      entry.Prompt,
+			Command:     entry.Command,
+			Explanation: entry.Explanation,
+			Accepted:    true,
+		}
+	}
+	return m
+}
+
+// UseAnalyzer assesses commands with analyzer, including any corrections.
+// A rerun matching one of its deny patterns stops at the confirm screen instead.
+func (m *AppModel) UseAnalyzer(analyzer *risk.Analyzer) {
+	m.analyzer = analyzer
+	m.generate.UseAnalyzer(analyzer)
+
+	if m.rerun != nil && !canRerun(analyzer, m.rerun.Command) {
+		m.rerun = nil
+	}
+}
+
+// CheckAliases counts the user's shell aliases as installed, including in corrections.
+// It's opt-in, since listing them means starting an interactive shell.
+func (m *AppModel) CheckAliases() {
+	m.checkAliases = true
+	m.generate.CheckAliases()
+}
+
+// canRerun reports whether command can run from history without stopping at the confirm screen
+func canRerun(analyzer *risk.Analyzer, command string) bool {
+	return analyzer.Analyze(command).Level == risk.LOW
+}
+
+func (m AppModel) Result() AppResult {
+	return AppResult{
+		Runs:       m.runs,
+		Command:    m.command,
+		Cancelled:  m.cancelled,
+		Err:        m.err,
+		HistoryErr: m.historyErr,
+	}
+}
+
+// Dispose stops the command if the program ended while it was still running
+func (m *AppModel) Dispose() {
+	if m.state == stateOutput {
+		m.output.Dispose()
+	}
+}
+
+func (m AppModel) Init() tea.Cmd {
+	if m.rerun != nil {
+		// as if the command had just been accepted in the generate view
+		result := *m.rerun
+		return func() tea.Msg {
+			return generateview.DoneMsg{Result: result}
+		}
+	}
+	return m.generate.Init()
+}
+
+func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowSize = msg
+	case generateview.DoneMsg:
+		if m.state != stateGenerate {
+			return m, nil
+		}
+		return m.generateDone(msg.Result)
+	case outputview.DoneMsg:
+		if m.state != stateOutput {
+			return m, nil
+		}
+		return m.outputDone(msg.Result)
+	case historyRecordedMsg:
+		if msg.err != nil {
+			m.historyErr = msg.err
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	switch m.state {
+	case stateGenerate:
+		var model tea.Model
+		model, cmd = m.generate.Update(msg)
+		m.generate = model.(generateview.GenerateModel)
+	case stateOutput:
+		var model tea.Model
+		model, cmd = m.output.Update(msg)
+		m.output = model.(outputview.OutputModel)
+	}
+
+	return m, cmd
+}
+
+func (m AppModel) View() string {
+	switch m.state {
+	case stateGenerate:
+		return m.generate.View()
+	case stateOutput:
+		return m.output.View()
+	}
+	return ""
+}
+
+func (m AppModel) generateDone(result generateview.GenerateResult) (tea.Model, tea.Cmd) {
+	if result.Agent != nil {
+		m.agent = result.Agent
+	}
+
+	if result.Err != nil || !result.Accepted {
+		m.err = result.Err
+		m.cancelled = result.Err == nil
+		return m.quit()
+	}
+
+	m.generated = result
+
+	if m.noRun {
+		m.command = result.Command
+		return m.quit(m.record(history.VERDICT_NOT_RUN, nil))
+	}
+
+	// the command starts once it's accepted, so the output view is created here rather than up front
+	output, err := outputview.NewOutputModel(result.Prompt, result.Command, m.runOptions, m.windowSize)
+	if err != nil {
+		m.err = err
+		return m.quit()
+	}
+
+	model, sizeCmd := output.Update(m.windowSize)
+	m.output = model.(outputview.OutputModel)
+	m.state = stateOutput
+
+	return m, tea.Batch(m.output.Init(), sizeCmd, tea.EnableMouseCellMotion)
+}
+
+func (m AppModel) outputDone(result outputview.OutputResult) (tea.Model, tea.Cmd) {
+	m.output.Dispose()
+	m.runs = append(m.runs, Run{Command: m.output.Command(), Output: result})
+
+	verdict := history.VERDICT_DONE
+	switch {
+	case result.DidntWork:
+		verdict = history.VERDICT_DIDNT_WORK
+	case result.Cancelled:
+		verdict = history.VERDICT_CANCELLED
+	}
+	record := m.record(verdict, &result.ExitCode)
+
+	if !result.DidntWork {
+		m.cancelled = result.Cancelled
+		return m.quit(record)
+	}
+
+	agentCh := generateview.LoadedAgent(m.agent)
+	// a rerun from history never went through the generate view, so the agent may still be loading
+	if m.agent == nil {
+		agentCh = m.agentCh
+	}
+
+	m.generate = generateview.NewCorrectionModel(agentCh, ai.Correction{
+		Prompt:   m.output.Prompt(),
+		Command:  m.output.Command(),
+		ExitCode: result.ExitCode,
+		Output:   result.Output,
+		Feedback: result.Feedback,
+	})
+	if m.analyzer != nil {
+		m.generate.UseAnalyzer(m.analyzer)
+	}
+	if m.checkAliases {
+		m.generate.CheckAliases()
+	}
+	m.state = stateGenerate
+
+	return m, tea.Batch(record, m.generate.Init(), tea.DisableMouse)
+}
+
+// quit ends the program once cmds (e.g. saving to history) have finished
+func (m AppModel) quit(cmds ...tea.Cmd) (tea.Model, tea.Cmd) {
+	m.state = stateDone
+	return m, tea.Sequence(append(cmds, tea.Quit)...)
+}
+
+type historyRecordedMsg struct {
+	err error
+}
+
+// record saves the accepted command to history along with what became of it
+func (m AppModel) record(verdict string, exitCode *int) tea.Cmd {
+	entry := history.Entry{
+		Time:        time.Now(),
+		Prompt:      m.generated.Prompt,
+		Command:     m.generated.Command,
+		Explanation: m.generated.Explanation,
+		ExitCode:    exitCode,
+		Verdict:     verdict,
+	}
+	if m.agent != nil {
+		entry.Model = m.agent.ModelName()
+	}
+	// edits show where the model got it wrong
+	if generated := m.generated.Generated; generated != "" && generated != entry.Command {
+		entry.Generated = generated
+		entry.Diff = history.WordDiff(generated, entry.Command)
+	}
+
+	return func() tea.Msg {
+		entry.Dir = m.runOptions.Dir
+		if entry.Dir == "" {
+			entry.Dir, _ = os.Getwd()
+		}
+		return historyRecordedMsg{err: history.Append(entry)}
+	}
+}
@@ -0,0 +1,55 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/azvaliev/cmd/internal/pkg/history"
+	"github.com/azvaliev/cmd/internal/pkg/risk"
+	outputview "github.com/azvaliev/cmd/internal/pkg/ui/views/output"
+)
+
+func TestHistoryRerun(t *testing.T) {
+	analyzer, err := risk.NewAnalyzer([]string{"forbidden"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		analyzer *risk.Analyzer
+		want     bool
+	}{
+		{"low risk", "ls -la", nil, true},
+		{"medium risk", "sudo apt update", nil, false},
+		{"high risk without an analyzer", "rm -rf /", nil, false},
+		{"high risk with an analyzer", "rm -rf /", analyzer, false},
+		{"denied", "echo forbidden", analyzer, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := history.Entry{Prompt: "test", Command: test.command}
+			m := NewHistoryAppModel(nil, entry, outputview.RunOptions{}, false, true)
+			if test.analyzer != nil {
+				m.UseAnalyzer(test.analyzer)
+			}
+
+			if got := m.rerun != nil; got != test.want {
+				t.Errorf("reruns %q = %v, want %v", test.command, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHistoryRerunDir(t *testing.T) {
+	dir := t.TempDir()
+	entry := history.Entry{Prompt: "test", Command: "ls", Dir: dir}
+
+	if m := NewHistoryAppModel(nil, entry, outputview.RunOptions{}, false, true); m.runOptions.Dir != dir {
+		t.Errorf("rerun runs in %q, want %q", m.runOptions.Dir, dir)
+	}
+	if m := NewHistoryAppModel(nil, entry, outputview.RunOptions{}, false, false); m.runOptions.Dir != "" {
+		t.Errorf("opened entry runs in %q, want the current directory", m.runOptions.Dir)
+	}
+}
@@ -1,11 +1,27 @@
 package views
 
 import (
+	"context"
+
 	"github.com/atotto/clipboard"
 	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/history"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// DoneMsg is sent once the user has accepted or dismissed the command,
+// leaving the next step to the parent model
+type DoneMsg struct {
+	Result GenerateResult
+}
+
+func (m GenerateModel) done() tea.Cmd {
+	result := m.Result()
+	return func() tea.Msg {
+		return DoneMsg{Result: result}
+	}
+}
+
 type agentLoadedResultMsg struct {
 	agent *ai.CommandAgent
 	err   error
@@ -18,30 +34,135 @@ func waitForAgentLoaded(ch <-chan AgentResult) tea.Cmd {
 	}
 }
 
+// Streaming responses are delivered over a channel: each chunk message carries the
+// stream so Update can wait for the next one, and the final result message ends it.
+// Every message is tagged with the request that produced it, so anything still in
+// flight from a cancelled request can be told apart and dropped.
+// The stream is closed once the request ends, which unblocks any waiting reader with a nil msg.
+func waitForStream(stream <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-stream
+	}
+}
+
+// send delivers msg unless the request was cancelled, in which case nobody
+// is reading the stream anymore and blocking would leak the goroutine
+func send(ctx context.Context, stream chan<- tea.Msg, msg tea.Msg) {
+	select {
+	case stream <- msg:
+	case <-ctx.Done():
+	}
+}
+
+type generateChunkMsg struct {
+	requestID int
+	chunk     string
+	stream    <-chan tea.Msg
+}
+
 type generateResultMsg struct {
-	command string
-	err     error
+	requestID int
+	command   string
+	err       error
+}
+
+func generateCommand(ctx context.Context, requestID int, agent *ai.CommandAgent, prompt string) tea.Cmd {
+	return func() tea.Msg {
+		stream := make(chan tea.Msg)
+		go func() {
+			command, err := agent.Generate(ctx, prompt, func(chunk string) {
+				send(ctx, stream, generateChunkMsg{requestID, chunk, stream})
+			})
+			send(ctx, stream, generateResultMsg{requestID, command, err})
+			close(stream)
+		}()
+		return <-stream
+	}
 }
 
-func generateCommand(agent *ai.CommandAgent, prompt string) tea.Cmd {
+// correctCommand regenerates a command that didn't work, see generateCommand
+func correctCommand(ctx context.Context, requestID int, agent *ai.CommandAgent, correction ai.Correction) tea.Cmd {
 	return func() tea.Msg {
-		command, err := agent.Generate(prompt)
-		return generateResultMsg{command, err}
+		stream := make(chan tea.Msg)
+		go func() {
+			command, err := agent.Correct(ctx, correction, func(chunk string) {
+				send(ctx, stream, generateChunkMsg{requestID, chunk, stream})
+			})
+			send(ctx, stream, generateResultMsg{requestID, command, err})
+			close(stream)
+		}()
+		return <-stream
 	}
 }
 
+// withoutCommand regenerates a command without tools that aren't installed, see generateCommand
+func withoutCommand(ctx context.Context, requestID int, agent *ai.CommandAgent, prompt string, tools []string) tea.Cmd {
+	return func() tea.Msg {
+		stream := make(chan tea.Msg)
+		go func() {
+			command, err := agent.Without(ctx, prompt, tools, func(chunk string) {
+				send(ctx, stream, generateChunkMsg{requestID, chunk, stream})
+			})
+			send(ctx, stream, generateResultMsg{requestID, command, err})
+			close(stream)
+		}()
+		return <-stream
+	}
+}
+
+// teachCommand learns tool and then generates the command for prompt,
+// streaming the result through the same messages as generateCommand
+func teachCommand(ctx context.Context, requestID int, agent *ai.CommandAgent, prompt, tool string) tea.Cmd {
+	return func() tea.Msg {
+		stream := make(chan tea.Msg)
+		go func() {
+			command, err := agent.Teach(ctx, prompt, tool, func(chunk string) {
+				send(ctx, stream, generateChunkMsg{requestID, chunk, stream})
+			})
+			send(ctx, stream, generateResultMsg{requestID, command, err})
+			close(stream)
+		}()
+		return <-stream
+	}
+}
+
+type explainChunkMsg struct {
+	requestID int
+	chunk     string
+	stream    <-chan tea.Msg
+}
+
 type explainResultMsg struct {
+	requestID   int
 	explanation string
 	err         error
 }
 
-func explainCommand(agent *ai.CommandAgent, prompt, command string) tea.Cmd {
+func explainCommand(ctx context.Context, requestID int, agent *ai.CommandAgent, prompt, command string) tea.Cmd {
 	return func() tea.Msg {
-		explanation, err := agent.Explain(prompt, command)
-		return explainResultMsg{explanation, err}
+		stream := make(chan tea.Msg)
+		go func() {
+			explanation, err := agent.Explain(ctx, prompt, command, func(chunk string) {
+				send(ctx, stream, explainChunkMsg{requestID, chunk, stream})
+			})
+			send(ctx, stream, explainResultMsg{requestID, explanation, err})
+			close(stream)
+		}()
+		return <-stream
 	}
 }
 
+type historyLoadedMsg struct {
+	entries []history.Entry
+}
+
+// loadHistory reads past prompts for recall. Without them the input
+// works as usual, so a missing or unreadable history isn't an error.
+func loadHistory() tea.Msg {
+	entries, _ := history.Load()
+	return historyLoadedMsg{entries: entries}
+}
+
 type clipboardCopiedMsg struct{}
 
 func copyToClipboard(text string) tea.Cmd {
@@ -0,0 +1,134 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/azvaliev/cmd/internal/pkg/ui/components"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// the textarea grows with the command up to this many lines, then scrolls
+const maxEditLines = 10
+
+func newEditInput(keys keyMap) textarea.Model {
+	ta := textarea.New()
+	ta.Prompt = ""
+	ta.ShowLineNumbers = false
+	ta.SetWidth(78)
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	// enter accepts the edit, so new lines (e.g. for \ continuations) need another key
+	ta.KeyMap.InsertNewline = keys.Newline
+	return ta
+}
+
+func (m *GenerateModel) startEditing() tea.Cmd {
+	m.state = stateEditing
+	m.editError = nil
+	m.editInput.SetValue(m.command)
+	m.editInput.SetHeight(min(maxEditLines, strings.Count(m.command, "\n")+1))
+	return m.editInput.Focus()
+}
+
+func (m GenerateModel) updateEditing(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(keyMsg, m.keys.Save):
+			m.editInput.Blur()
+			m.state = stateConfirm
+			if m.setEditedCommand(m.editInput.Value()) {
+				return m, m.checkExecutables()
+			}
+			return m, nil
+		case key.Matches(keyMsg, m.keys.Cancel):
+			m.editInput.Blur()
+			m.state = stateConfirm
+			return m, nil
+		}
+	}
+
+	// room for a new line up front, otherwise the textarea scrolls the first line out of view
+	m.editInput.SetHeight(min(maxEditLines, m.editInput.LineCount()+1))
+	var cmd tea.Cmd
+	m.editInput, cmd = m.editInput.Update(msg)
+	m.editInput.SetHeight(min(maxEditLines, m.editInput.LineCount()))
+	return m, cmd
+}
+
+// setEditedCommand replaces the command, unless the edit left nothing to run,
+// reporting whether it changed
+func (m *GenerateModel) setEditedCommand(command string) bool {
+	command = strings.TrimSpace(command)
+	if command == "" || command == m.command {
+		return false
+	}
+
+	m.command = command
+	m.risk = m.analyzer.Analyze(command)
+	// it explained the old command
+	m.explanation = ""
+	return true
+}
+
+func (m GenerateModel) viewEditing() string {
+	var sections []string
+
+	sections = append(sections, components.RenderPrompt(m.prompt))
+	sections = append(sections, m.editInput.View())
+	sections = append(sections, m.help.ShortHelpView([]key.Binding{m.keys.Save, m.keys.Newline, m.keys.Cancel}))
+
+	return strings.Join(sections, "\n\n")
+}
+
+type editorFinishedMsg struct {
+	command string
+	err     error
+}
+
+// openEditor hands the terminal to $VISUAL or $EDITOR (falling back to vi)
+// to edit command in a temp file, reading it back once the editor exits
+func openEditor(command string) tea.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "cmd-*.sh")
+	if err != nil {
+		return func() tea.Msg {
+			return editorFinishedMsg{err: err}
+		}
+	}
+	path := file.Name()
+
+	_, err = file.WriteString(command + "\n")
+	if err = errors.Join(err, file.Close()); err != nil {
+		os.Remove(path)
+		return func() tea.Msg {
+			return editorFinishedMsg{err: err}
+		}
+	}
+
+	// the variable may include flags, e.g. "code --wait"
+	args := append(strings.Fields(editor), path)
+	proc := exec.Command(args[0], args[1:]...)
+
+	return tea.ExecProcess(proc, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("%s: %w", editor, err)}
+		}
+
+		edited, err := os.ReadFile(path)
+		return editorFinishedMsg{command: string(edited), err: err}
+	})
+}
@@ -10,6 +10,17 @@ type keyMap struct {
 	Explain key.Binding
 	Copy    key.Binding
 	Cancel  key.Binding
+	Teach   key.Binding
+	Search  key.Binding
+
+	Edit         key.Binding
+	EditExternal key.Binding
+	Save         key.Binding
+	Newline      key.Binding
+
+	// for commands that aren't installed
+	Install key.Binding
+	Without key.Binding
 }
 
 var _ help.KeyMap = (*keyMap)(nil)
@@ -32,11 +43,43 @@ func newKeyMap() keyMap {
 			key.WithKeys("esc"),
 			key.WithHelp("[esc]", "cancel"),
 		),
+		Teach: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("[enter]", "teach"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("ctrl+r"),
+			key.WithHelp("[ctrl+r]", "search history"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("[e]", "edit"),
+		),
+		EditExternal: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("[E]", "$EDITOR"),
+		),
+		Save: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("[enter]", "save"),
+		),
+		Newline: key.NewBinding(
+			key.WithKeys("alt+enter", "ctrl+j"),
+			key.WithHelp("[ctrl+j]", "new line"),
+		),
+		Install: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("[i]", "install"),
+		),
+		Without: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("[r]", "regenerate"),
+		),
 	}
 }
 
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Run, k.Explain, k.Copy, k.Cancel}
+	return []key.Binding{k.Run, k.Install, k.Without, k.Explain, k.Edit, k.EditExternal, k.Copy, k.Cancel}
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
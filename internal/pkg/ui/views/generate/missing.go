@@ -0,0 +1,84 @@
+package views
+
+import (
+	"strings"
+
+	"github.com/azvaliev/cmd/internal/pkg/shell"
+	"github.com/azvaliev/cmd/internal/pkg/sysinfo"
+	"github.com/azvaliev/cmd/internal/pkg/ui/components"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type executablesCheckedMsg struct {
+	command string
+	missing []string
+	// installs the missing commands, empty without a known package manager
+	install string
+}
+
+// CheckAliases counts the user's shell aliases as installed commands, see shell.WithoutAliases
+func (m *GenerateModel) CheckAliases() {
+	m.checkAliases = true
+}
+
+// checkExecutables looks for commands the current command runs that aren't installed.
+// It's done in the background, since it can mean starting the user's shell to list aliases.
+func (m *GenerateModel) checkExecutables() tea.Cmd {
+	m.missing, m.install = nil, ""
+
+	command := m.command
+	checkAliases := m.checkAliases
+	return func() tea.Msg {
+		file, err := shell.Parse(command)
+		if err != nil {
+			return executablesCheckedMsg{command: command}
+		}
+
+		missing := shell.Missing(shell.Executables(file))
+		if checkAliases {
+			missing = shell.WithoutAliases(missing)
+		}
+		if len(missing) == 0 {
+			return executablesCheckedMsg{command: command}
+		}
+
+		return executablesCheckedMsg{
+			command: command,
+			missing: missing,
+			install: sysinfo.DetectPackageManager().InstallCommand(missing),
+		}
+	}
+}
+
+// installMissing runs the install command ahead of the command
+func (m *GenerateModel) installMissing() {
+	if m.install == "" {
+		return
+	}
+	m.setEditedCommand(m.install + " && " + m.command)
+	// they're only installed once it runs
+	m.missing, m.install = nil, ""
+}
+
+// regenerateWithout asks for another command that doesn't use the missing ones
+func (m *GenerateModel) regenerateWithout() tea.Cmd {
+	if len(m.missing) == 0 || m.agent == nil {
+		return nil
+	}
+	m.without = m.missing
+	m.state = stateGenerating
+	return tea.Batch(m.spinner.Tick, m.generate())
+}
+
+// viewMissing lists the commands that aren't installed and how to install them, empty when there are none
+func (m GenerateModel) viewMissing() string {
+	if len(m.missing) == 0 {
+		return ""
+	}
+
+	missing := mediumRiskStyle.Render("Not installed: " + strings.Join(m.missing, ", "))
+	if m.install != "" {
+		missing += "\n" + components.FaintStyle.Render("install with "+m.install)
+	}
+	return missing
+}
@@ -0,0 +1,217 @@
+package views
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/azvaliev/cmd/internal/pkg/history"
+	"github.com/azvaliev/cmd/internal/pkg/ui/components"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// how many matches the ctrl-r search shows at once
+const maxSearchResults = 8
+
+var selectedMatchStyle = lipgloss.NewStyle().Bold(true)
+
+// pastCommands orders history newest first, keeping only the latest of each prompt→command pair
+func pastCommands(entries []history.Entry) []history.Entry {
+	seen := make(map[[2]string]bool)
+	var past []history.Entry
+	for _, entry := range slices.Backward(entries) {
+		pair := [2]string{entry.Prompt, entry.Command}
+		if seen[pair] || entry.Prompt == "" {
+			continue
+		}
+		seen[pair] = true
+		past = append(past, entry)
+	}
+	return past
+}
+
+// pastPrompts lists each prompt once, newest first, for cycling with up/down
+func pastPrompts(past []history.Entry) []string {
+	var prompts []string
+	for _, entry := range past {
+		if !slices.Contains(prompts, entry.Prompt) {
+			prompts = append(prompts, entry.Prompt)
+		}
+	}
+	return prompts
+}
+
+// recallPrompt moves through past prompts, older for step 1 and newer for -1.
+// Stepping past the newest brings back whatever was being typed.
+func (m GenerateModel) recallPrompt(step int) (tea.Model, tea.Cmd) {
+	index := m.promptIndex + step
+	if index < -1 || index >= len(m.pastPrompts) {
+		return m, nil
+	}
+
+	if m.promptIndex == -1 {
+		m.draft = m.commandInput.Value()
+	}
+	m.promptIndex = index
+
+	if index == -1 {
+		m.commandInput.SetValue(m.draft)
+	} else {
+		m.commandInput.SetValue(m.pastPrompts[index])
+	}
+	m.commandInput.CursorEnd()
+	return m, nil
+}
+
+func (m *GenerateModel) startSearch() tea.Cmd {
+	m.state = stateSearch
+	m.commandInput.Blur()
+	m.searchInput.SetValue(m.commandInput.Value())
+	m.searchInput.CursorEnd()
+	m.updateSearchMatches()
+	return m.searchInput.Focus()
+}
+
+func (m *GenerateModel) updateSearchMatches() {
+	m.searchMatches = searchPastCommands(m.pastCommands, m.searchInput.Value())
+	m.searchSelected = 0
+}
+
+func (m GenerateModel) updateSearch(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.Type {
+		case tea.KeyUp:
+			m.searchSelected = max(0, m.searchSelected-1)
+			return m, nil
+		// like a shell, pressing ctrl-r again moves on to the next match
+		case tea.KeyDown, tea.KeyCtrlR:
+			// only the first maxSearchResults are shown, selecting past them would leave nothing highlighted
+			m.searchSelected = min(max(0, min(len(m.searchMatches), maxSearchResults)-1), m.searchSelected+1)
+			return m, nil
+		case tea.KeyEnter:
+			if len(m.searchMatches) == 0 {
+				return m, nil
+			}
+			// it's been generated before, no need to ask the model again
+			entry := m.searchMatches[m.searchSelected]
+			m.searchInput.Blur()
+			m.prompt = entry.Prompt
+			m.correction = nil
+			m.confirm(entry.Command, entry.Explanation)
+			return m, m.checkExecutables()
+		case tea.KeyTab:
+			if len(m.searchMatches) == 0 {
+				return m, nil
+			}
+			// submitted unchanged it still skips the model, see updateInput
+			entry := m.searchMatches[m.searchSelected]
+			m.recalled = &entry
+			m.searchInput.Blur()
+			m.state = stateInput
+			m.commandInput.SetValue(entry.Prompt)
+			m.commandInput.CursorEnd()
+			return m, m.commandInput.Focus()
+		case tea.KeyEsc:
+			m.searchInput.Blur()
+			m.state = stateInput
+			return m, m.commandInput.Focus()
+		}
+	}
+
+	query := m.searchInput.Value()
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	if m.searchInput.Value() != query {
+		m.updateSearchMatches()
+	}
+	return m, cmd
+}
+
+func (m GenerateModel) viewSearch() string {
+	var lines []string
+	for i, entry := range m.searchMatches[:min(len(m.searchMatches), maxSearchResults)] {
+		line := entry.Prompt + components.FaintStyle.Render(" → "+strings.ReplaceAll(entry.Command, "\n", " "))
+		if i == m.searchSelected {
+			lines = append(lines, selectedMatchStyle.Render("▸ ")+line)
+		} else {
+			lines = append(lines, "  "+line)
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, components.FaintStyle.Render("  no matches"))
+	}
+
+	// the match list is clipped rather than wrapped so the view doesn't jump around
+	list := lipgloss.NewStyle().MaxWidth(80).Render(strings.Join(lines, "\n"))
+	hint := components.FaintStyle.Render("enter use  tab edit prompt  ↑↓ select  esc back")
+
+	return m.searchInput.View() + "\n\n" + list + "\n\n" + hint
+}
+
+// searchPastCommands fuzzy matches query against prompts and commands,
+// best match first and newest first among equals
+func searchPastCommands(past []history.Entry, query string) []history.Entry {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return past
+	}
+
+	type match struct {
+		entry history.Entry
+		score int
+	}
+
+	var matches []match
+	for _, entry := range past {
+		score, ok := fuzzyScore(query, entry.Prompt)
+		if commandScore, commandOk := fuzzyScore(query, entry.Command); commandOk && (!ok || commandScore > score) {
+			score, ok = commandScore, true
+		}
+		if ok {
+			matches = append(matches, match{entry, score})
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b match) int {
+		return b.score - a.score
+	})
+
+	entries := make([]history.Entry, len(matches))
+	for i, match := range matches {
+		entries[i] = match.entry
+	}
+	return entries
+}
+
+// fuzzyScore reports whether the runes of query appear in text in order, ignoring case.
+// Runs of consecutive matches and matches at the start of a word score higher,
+// so abbreviations and partial words rank above letters scattered through the text.
+func fuzzyScore(query, text string) (int, bool) {
+	queryRunes := []rune(strings.ToLower(query))
+	textRunes := []rune(strings.ToLower(text))
+
+	score := 0
+	queryIndex := 0
+	lastMatch := -2
+	for i, r := range textRunes {
+		if queryIndex == len(queryRunes) {
+			break
+		}
+		if r != queryRunes[queryIndex] {
+			continue
+		}
+
+		score++
+		if lastMatch == i-1 {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(textRunes[i-1]) && !unicode.IsDigit(textRunes[i-1]) {
+			score += 3
+		}
+		lastMatch = i
+		queryIndex++
+	}
+
+	return score, queryIndex == len(queryRunes)
+}
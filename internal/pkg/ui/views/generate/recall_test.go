@@ -0,0 +1,72 @@
+package views
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/azvaliev/cmd/internal/pkg/history"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSearchSelectionStaysVisible(t *testing.T) {
+	tests := []struct {
+		matches int
+		presses int
+		want    int
+	}{
+		{matches: 0, presses: 3, want: 0},
+		{matches: 3, presses: 1, want: 1},
+		{matches: 3, presses: 5, want: 2},
+		{matches: maxSearchResults + 5, presses: maxSearchResults + 5, want: maxSearchResults - 1},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d matches, %d presses", test.matches, test.presses), func(t *testing.T) {
+			m := NewGenerateModel(nil, "")
+			m.state = stateSearch
+			for i := range test.matches {
+				m.searchMatches = append(m.searchMatches, history.Entry{Prompt: fmt.Sprint("prompt ", i), Command: "true"})
+			}
+
+			var model tea.Model = m
+			for range test.presses {
+				model, _ = model.(GenerateModel).updateSearch(tea.KeyMsg{Type: tea.KeyDown})
+			}
+
+			if got := model.(GenerateModel).searchSelected; got != test.want {
+				t.Errorf("searchSelected = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchPastCommands(t *testing.T) {
+	past := []history.Entry{
+		{Prompt: "list files by size", Command: "ls -lS"},
+		{Prompt: "find large files", Command: "find . -size +100M"},
+		{Prompt: "show disk usage", Command: "du -sh *"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"list files by size", "find large files", "show disk usage"}},
+		{"files", []string{"list files by size", "find large files"}},
+		{"du", []string{"show disk usage"}},
+		{"lfs", []string{"list files by size", "find large files"}},
+		{"zzz", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			var got []string
+			for _, entry := range searchPastCommands(past, test.query) {
+				got = append(got, entry.Prompt)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("searchPastCommands(%q) = %q, want %q", test.query, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package views
+
+import (
+	"strings"
+
+	"github.com/azvaliev/cmd/internal/pkg/risk"
+	"github.com/azvaliev/cmd/internal/pkg/ui/components"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// what has to be typed to run a high risk command
+const riskConfirmation = "yes"
+
+var (
+	mediumRiskStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	highRiskStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+)
+
+// UseAnalyzer replaces the default analyzer, e.g. with one that has the configured deny patterns
+func (m *GenerateModel) UseAnalyzer(analyzer *risk.Analyzer) {
+	m.analyzer = analyzer
+	if m.command != "" {
+		m.risk = analyzer.Analyze(m.command)
+	}
+}
+
+// accept runs the command, after a typed confirmation when it's high risk
+func (m GenerateModel) accept() (tea.Model, tea.Cmd) {
+	switch {
+	case m.risk.Denied():
+		return m, nil
+	case m.risk.Level == risk.HIGH:
+		m.state = stateConfirmRisk
+		m.riskInput.Reset()
+		return m, m.riskInput.Focus()
+	}
+
+	m.accepted = true
+	return m, m.done()
+}
+
+func (m GenerateModel) updateConfirmRisk(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(keyMsg, m.keys.Run):
+			if strings.TrimSpace(m.riskInput.Value()) != riskConfirmation {
+				return m, nil
+			}
+			m.riskInput.Blur()
+			m.accepted = true
+			return m, m.done()
+		case key.Matches(keyMsg, m.keys.Cancel):
+			m.riskInput.Blur()
+			m.state = stateConfirm
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.riskInput, cmd = m.riskInput.Update(msg)
+	return m, cmd
+}
+
+func (m GenerateModel) viewConfirmRisk() string {
+	var sections []string
+
+	sections = append(sections, components.RenderPrompt(m.prompt))
+	sections = append(sections, components.RenderCommand(m.command))
+	sections = append(sections, m.viewRisk())
+	sections = append(sections, m.riskInput.View())
+	m.keys.Cancel.SetHelp("[esc]", "back")
+	sections = append(sections, m.help.ShortHelpView([]key.Binding{m.keys.Run, m.keys.Cancel}))
+
+	return strings.Join(sections, "\n\n")
+}
+
+// viewRisk explains why the command was flagged, empty when it wasn't
+func (m GenerateModel) viewRisk() string {
+	switch {
+	case m.risk.Denied():
+		return highRiskStyle.Render("Refused: " + strings.Join(m.risk.Reasons, ", "))
+	case m.risk.Level == risk.HIGH:
+		return highRiskStyle.Render("High risk: " + strings.Join(m.risk.Reasons, ", "))
+	case m.risk.Level == risk.MEDIUM:
+		return mediumRiskStyle.Render("Caution: " + strings.Join(m.risk.Reasons, ", "))
+	}
+	return ""
+}
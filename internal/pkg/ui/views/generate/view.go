@@ -1,14 +1,21 @@
 package views
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/azvaliev/cmd/internal/pkg/ai"
+	"github.com/azvaliev/cmd/internal/pkg/exitcode"
+	"github.com/azvaliev/cmd/internal/pkg/history"
+	"github.com/azvaliev/cmd/internal/pkg/risk"
+	"github.com/azvaliev/cmd/internal/pkg/shell"
 	"github.com/azvaliev/cmd/internal/pkg/ui/components"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -23,7 +30,12 @@ type GenerateResult struct {
 	Prompt      string
 	Command     string
 	Explanation string
-	Accepted    bool
+	// the command as generated, differs from Command when the user edited it
+	Generated string
+	Accepted  bool
+	Err       error
+	// nil if it never finished loading, lets a follow-up model reuse it
+	Agent *ai.CommandAgent
 }
 
 type state int
@@ -33,6 +45,15 @@ const (
 	stateGenerating
 	stateConfirm
 	stateExplaining
+	// retrieval missed or the model didn't know, ask which tool to learn
+	stateTeaching
+	stateLearning
+	// ctrl-r search through past prompts and their commands
+	stateSearch
+	// changing the command before running it
+	stateEditing
+	// typing out the confirmation for a high risk command
+	stateConfirmRisk
 )
 
 type GenerateModel struct {
@@ -44,36 +65,145 @@ type GenerateModel struct {
 
 	prompt                    string
 	command                   string
+	generatedCommand          string
 	explanation               string
 	showCopiedFeedbackMessage bool
 	accepted                  bool
 
+	// identifies the in-flight generate/explain request, see waitForStream
+	requestID     int
+	cancelRequest context.CancelFunc
+
+	// set while regenerating a command that didn't work
+	correction *ai.Correction
+	// set while regenerating without commands that aren't installed
+	without []string
+
+	// tool being learned, and why the last attempt to learn failed
+	tool       string
+	teachError error
+
+	// from history, newest first, see pastCommands
+	pastCommands []history.Entry
+	pastPrompts  []string
+	// which past prompt the input shows while cycling with up/down, -1 for the draft
+	promptIndex int
+	draft       string
+	// picked from the search for editing, submitting its prompt unchanged reuses its command
+	recalled       *history.Entry
+	searchMatches  []history.Entry
+	searchSelected int
+
+	// why opening $EDITOR failed
+	editError error
+
+	// where the command runs, when it isn't the current directory, see RunIn
+	dir string
+
+	analyzer *risk.Analyzer
+	// of command, redone whenever it changes
+	risk risk.Assessment
+	// commands it runs that aren't installed, and how to install them, see checkExecutables
+	missing      []string
+	install      string
+	checkAliases bool
+
 	commandInput textinput.Model
+	teachInput   textinput.Model
+	searchInput  textinput.Model
+	editInput    textarea.Model
+	riskInput    textinput.Model
 	spinner      spinner.Model
 	help         help.Model
 	keys         keyMap
 }
 
-func NewGenerateModel(agentCh <-chan AgentResult) GenerateModel {
+// NewGenerateModel starts in the input state, or when prompt is given
+// (e.g. from command-line args), goes straight to generating it
+func NewGenerateModel(agentCh <-chan AgentResult, prompt string) GenerateModel {
 	ti := textinput.New()
 	ti.Prompt = "> "
 	ti.Placeholder = "describe the command you'd like to generate"
 	ti.Width = 80
 	ti.PromptStyle = lipgloss.NewStyle().Faint(true)
 	ti.PlaceholderStyle = lipgloss.NewStyle().Faint(true)
-	ti.Focus()
+
+	teachInput := textinput.New()
+	teachInput.Prompt = "> "
+	teachInput.Placeholder = "command name, e.g. ncdu"
+	teachInput.Width = 40
+	teachInput.PromptStyle = lipgloss.NewStyle().Faint(true)
+	teachInput.PlaceholderStyle = lipgloss.NewStyle().Faint(true)
+
+	searchInput := textinput.New()
+	searchInput.Prompt = "history > "
+	searchInput.Placeholder = "search past prompts and commands"
+	searchInput.Width = 60
+	searchInput.PromptStyle = lipgloss.NewStyle().Faint(true)
+	searchInput.PlaceholderStyle = lipgloss.NewStyle().Faint(true)
+
+	riskInput := textinput.New()
+	riskInput.Prompt = "> "
+	riskInput.Placeholder = "type " + riskConfirmation + " to run it"
+	riskInput.Width = 40
+	riskInput.PromptStyle = lipgloss.NewStyle().Faint(true)
+	riskInput.PlaceholderStyle = lipgloss.NewStyle().Faint(true)
 
 	s := spinner.New()
 	s.Spinner = components.DotBounceSpinner
 
-	return GenerateModel{
+	keys := newKeyMap()
+
+	m := GenerateModel{
 		agentCh:      agentCh,
 		state:        stateInput,
+		promptIndex:  -1,
 		commandInput: ti,
+		teachInput:   teachInput,
+		searchInput:  searchInput,
+		editInput:    newEditInput(keys),
+		riskInput:    riskInput,
+		analyzer:     &risk.Analyzer{},
 		spinner:      s,
 		help:         components.NewHelp(),
-		keys:         newKeyMap(),
+		keys:         keys,
+	}
+
+	if prompt != "" {
+		// the command is generated as soon as the agent finishes loading
+		m.prompt = prompt
+		m.state = stateGenerating
+	} else {
+		m.commandInput.Focus()
 	}
+
+	return m
+}
+
+// NewCorrectionModel goes straight to regenerating the command described by correction
+func NewCorrectionModel(agentCh <-chan AgentResult, correction ai.Correction) GenerateModel {
+	m := NewGenerateModel(agentCh, correction.Prompt)
+	m.correction = &correction
+	return m
+}
+
+// NewConfirmModel starts at the confirm screen for a command generated earlier, e.g. from history
+func NewConfirmModel(agentCh <-chan AgentResult, prompt, command, explanation string) GenerateModel {
+	m := NewGenerateModel(agentCh, prompt)
+	m.confirm(command, explanation)
+	return m
+}
+
+// RunIn notes in the confirm screen that the command runs in dir rather than the current directory
+func (m *GenerateModel) RunIn(dir string) {
+	m.dir = dir
+}
+
+// LoadedAgent hands an already created agent to a new model
+func LoadedAgent(agent *ai.CommandAgent) <-chan AgentResult {
+	agentCh := make(chan AgentResult, 1)
+	agentCh <- AgentResult{Agent: agent}
+	return agentCh
 }
 
 func (m GenerateModel) Result() GenerateResult {
@@ -81,12 +211,21 @@ func (m GenerateModel) Result() GenerateResult {
 		Prompt:      m.prompt,
 		Command:     m.command,
 		Explanation: m.explanation,
+		Generated:   m.generatedCommand,
 		Accepted:    m.accepted,
+		Err:         m.err,
+		Agent:       m.agent,
 	}
 }
 
 func (m GenerateModel) Init() tea.Cmd {
-	return tea.Batch(waitForAgentLoaded(m.agentCh), textinput.Blink)
+	switch m.state {
+	case stateGenerating:
+		return tea.Batch(waitForAgentLoaded(m.agentCh), loadHistory, m.spinner.Tick)
+	case stateConfirm:
+		return tea.Batch(waitForAgentLoaded(m.agentCh), loadHistory, m.checkExecutables())
+	}
+	return tea.Batch(waitForAgentLoaded(m.agentCh), loadHistory, textinput.Blink)
 }
 
 func (m GenerateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -94,14 +233,20 @@ func (m GenerateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		{
 			if msg.Type == tea.KeyCtrlC {
-				return m, tea.Quit
+				m.endRequest()
+				return m, m.done()
+			}
+			// errors stay on screen until dismissed, otherwise the alt-screen
+			// would close before there's a chance to read them
+			if m.err != nil {
+				return m, m.done()
 			}
 		}
 	case agentLoadedResultMsg:
 		{
 			if msg.err != nil {
 				m.err = msg.err
-				return m, tea.Quit
+				return m, nil
 			}
 
 			m.agent = msg.agent
@@ -109,31 +254,71 @@ func (m GenerateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			promptSubmitted := m.state == stateGenerating && m.prompt != ""
 			if promptSubmitted {
 				// run the prompt immediatelyy
-				return m, generateCommand(m.agent, m.prompt)
+				return m, m.generate()
 			}
 			return m, nil
 		}
+	case generateChunkMsg:
+		{
+			if msg.requestID != m.requestID {
+				return m, nil
+			}
+
+			m.command += msg.chunk
+			return m, waitForStream(msg.stream)
+		}
 	case generateResultMsg:
 		{
+			if msg.requestID != m.requestID {
+				return m, nil
+			}
+			m.endRequest()
+
+			if errors.Is(msg.err, ai.ErrNoContext) || errors.Is(msg.err, ai.ErrUnknownCommand) {
+				if m.state == stateLearning {
+					m.teachError = fmt.Errorf("still not sure after learning %s", m.tool)
+				}
+				return m, m.startTeaching()
+			}
+
+			// a tool that can't be learned (not installed, no help) is worth another try
+			if msg.err != nil && m.state == stateLearning {
+				m.teachError = msg.err
+				return m, m.startTeaching()
+			}
+
 			if msg.err != nil {
-				m.err = msg.err
-				return m, tea.Quit
+				m.err = exitcode.Wrap(exitcode.GENERATE_FAILED, msg.err)
+				return m, nil
 			}
 
 			if msg.command == "" {
-				m.err = fmt.Errorf("received empty command from agent")
-				return m, tea.Quit
+				m.err = exitcode.Wrap(exitcode.GENERATE_FAILED, fmt.Errorf("received empty command from agent"))
+				return m, nil
 			}
 
-			m.command = msg.command
-			m.state = stateConfirm
-			return m, nil
+			m.confirm(msg.command, "")
+			return m, m.checkExecutables()
+		}
+	case explainChunkMsg:
+		{
+			if msg.requestID != m.requestID {
+				return m, nil
+			}
+
+			m.explanation += msg.chunk
+			return m, waitForStream(msg.stream)
 		}
 	case explainResultMsg:
 		{
+			if msg.requestID != m.requestID {
+				return m, nil
+			}
+			m.endRequest()
+
 			if msg.err != nil {
-				m.err = msg.err
-				return m, tea.Quit
+				m.err = exitcode.Wrap(exitcode.GENERATE_FAILED, msg.err)
+				return m, nil
 			}
 
 			m.explanation = msg.explanation
@@ -145,6 +330,27 @@ func (m GenerateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.showCopiedFeedbackMessage = true
 			return m, nil
 		}
+	case editorFinishedMsg:
+		{
+			m.editError = msg.err
+			if msg.err == nil && m.setEditedCommand(msg.command) {
+				return m, m.checkExecutables()
+			}
+			return m, nil
+		}
+	case executablesCheckedMsg:
+		{
+			if msg.command == m.command {
+				m.missing, m.install = msg.missing, msg.install
+			}
+			return m, nil
+		}
+	case historyLoadedMsg:
+		{
+			m.pastCommands = pastCommands(msg.entries)
+			m.pastPrompts = pastPrompts(m.pastCommands)
+			return m, nil
+		}
 	}
 
 	switch m.state {
@@ -156,6 +362,16 @@ func (m GenerateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.updateConfirm(msg)
 	case stateExplaining:
 		return m.updateExplaining(msg)
+	case stateTeaching:
+		return m.updateTeaching(msg)
+	case stateLearning:
+		return m.updateLearning(msg)
+	case stateSearch:
+		return m.updateSearch(msg)
+	case stateEditing:
+		return m.updateEditing(msg)
+	case stateConfirmRisk:
+		return m.updateConfirmRisk(msg)
 	}
 
 	return m, nil
@@ -165,7 +381,7 @@ func (m GenerateModel) View() string {
 	var content string
 
 	if m.err != nil {
-		content = fmt.Sprintf("Error: %v", m.err)
+		content = components.RenderError(m.err) + "\n\n" + components.FaintStyle.Render("press any key to exit")
 	} else {
 		switch m.state {
 		case stateInput:
@@ -176,6 +392,16 @@ func (m GenerateModel) View() string {
 			content = m.viewConfirm()
 		case stateExplaining:
 			content = m.viewExplaining()
+		case stateTeaching:
+			content = m.viewTeaching()
+		case stateLearning:
+			content = m.viewLearning()
+		case stateSearch:
+			content = m.viewSearch()
+		case stateEditing:
+			content = m.viewEditing()
+		case stateConfirmRisk:
+			content = m.viewConfirmRisk()
 		}
 	}
 
@@ -183,18 +409,44 @@ func (m GenerateModel) View() string {
 }
 
 func (m GenerateModel) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case keyMsg.Type == tea.KeyUp:
+			return m.recallPrompt(1)
+		case keyMsg.Type == tea.KeyDown:
+			return m.recallPrompt(-1)
+		case key.Matches(keyMsg, m.keys.Search):
+			return m, m.startSearch()
+		}
+	}
+
 	// submit on enter
 	if keyMsg, ok := msg.(tea.KeyMsg); ok && keyMsg.Type == tea.KeyEnter {
 		value := strings.TrimSpace(m.commandInput.Value())
 		if value == "" {
 			return m, nil
 		}
+
+		recalled := m.recalled
+		m.recalled = nil
+		m.promptIndex = -1
+		if recalled != nil && value == recalled.Prompt {
+			m.prompt = value
+			m.correction = nil
+			m.confirm(recalled.Command, recalled.Explanation)
+			m.commandInput.Blur()
+			return m, m.checkExecutables()
+		}
+
 		m.prompt = value
 		m.state = stateGenerating
+		// an edited prompt is a fresh request rather than a correction
+		m.correction = nil
+		m.without = nil
 		m.commandInput.Blur()
 
 		if m.agent != nil {
-			return m, tea.Batch(m.spinner.Tick, generateCommand(m.agent, m.prompt))
+			return m, tea.Batch(m.spinner.Tick, m.generate())
 		}
 		return m, m.spinner.Tick
 	}
@@ -205,6 +457,12 @@ func (m GenerateModel) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m GenerateModel) updateGenerating(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// back to the input with the prompt preserved, so it can be tweaked and resubmitted
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && key.Matches(keyMsg, m.keys.Cancel) {
+		m.endRequest()
+		return m, m.returnToInput()
+	}
+
 	var cmd tea.Cmd
 	// keeeeeep spinning away
 	m.spinner, cmd = m.spinner.Update(msg)
@@ -223,16 +481,35 @@ func (m GenerateModel) updateConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(keyMsg, m.keys.Run):
 		{
-			m.accepted = true
-			return m, tea.Quit
+			return m.accept()
+		}
+	case key.Matches(keyMsg, m.keys.Install):
+		{
+			m.installMissing()
+			return m, nil
+		}
+	case key.Matches(keyMsg, m.keys.Without):
+		{
+			return m, m.regenerateWithout()
 		}
 	case key.Matches(keyMsg, m.keys.Explain):
 		{
-			if m.explanation != "" {
+			// the confirm screen can be reached before the agent loads, see NewConfirmModel
+			if m.explanation != "" || m.agent == nil {
 				return m, nil
 			}
 			m.state = stateExplaining
-			return m, tea.Batch(m.spinner.Tick, explainCommand(m.agent, m.prompt, m.command))
+			ctx := m.startRequest()
+			return m, tea.Batch(m.spinner.Tick, explainCommand(ctx, m.requestID, m.agent, m.prompt, m.command))
+		}
+	case key.Matches(keyMsg, m.keys.Edit):
+		{
+			return m, m.startEditing()
+		}
+	case key.Matches(keyMsg, m.keys.EditExternal):
+		{
+			m.editError = nil
+			return m, openEditor(m.command)
 		}
 	case key.Matches(keyMsg, m.keys.Copy):
 		{
@@ -241,7 +518,7 @@ func (m GenerateModel) updateConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	case key.Matches(keyMsg, m.keys.Cancel):
 		{
-			return m, tea.Quit
+			return m, m.done()
 		}
 	}
 
@@ -249,19 +526,148 @@ func (m GenerateModel) updateConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m GenerateModel) updateExplaining(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && key.Matches(keyMsg, m.keys.Cancel) {
+		m.endRequest()
+		m.state = stateConfirm
+		m.explanation = ""
+		return m, nil
+	}
+
 	var cmd tea.Cmd
 	// keeeeeep spinning away
 	m.spinner, cmd = m.spinner.Update(msg)
 	return m, cmd
 }
 
+func (m GenerateModel) updateTeaching(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(keyMsg, m.keys.Teach):
+			tool := strings.TrimSpace(m.teachInput.Value())
+			if tool == "" {
+				return m, nil
+			}
+
+			m.tool = tool
+			m.teachError = nil
+			m.state = stateLearning
+			m.command = ""
+			m.teachInput.Blur()
+
+			ctx := m.startRequest()
+			return m, tea.Batch(m.spinner.Tick, teachCommand(ctx, m.requestID, m.agent, m.prompt, m.tool))
+		case key.Matches(keyMsg, m.keys.Cancel):
+			// back to the prompt, it may just need rephrasing
+			m.teachError = nil
+			m.teachInput.Blur()
+			return m, m.returnToInput()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.teachInput, cmd = m.teachInput.Update(msg)
+	return m, cmd
+}
+
+func (m GenerateModel) updateLearning(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && key.Matches(keyMsg, m.keys.Cancel) {
+		m.endRequest()
+		m.command = ""
+		return m, m.startTeaching()
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+func (m *GenerateModel) startTeaching() tea.Cmd {
+	m.state = stateTeaching
+	m.command = ""
+	m.teachInput.SetValue(m.tool)
+	m.teachInput.CursorEnd()
+	return m.teachInput.Focus()
+}
+
+// confirm shows command for the user to accept, as it was generated (or accepted before)
+func (m *GenerateModel) confirm(command, explanation string) {
+	m.command = command
+	m.generatedCommand = command
+	m.explanation = explanation
+	m.risk = m.analyzer.Analyze(command)
+	m.without = nil
+	m.state = stateConfirm
+}
+
+// returnToInput goes back to editing the prompt, keeping what was typed
+func (m *GenerateModel) returnToInput() tea.Cmd {
+	m.state = stateInput
+	m.command = ""
+	m.commandInput.SetValue(m.prompt)
+	m.commandInput.CursorEnd()
+	return m.commandInput.Focus()
+}
+
+// startRequest returns the context for a new generate/explain request,
+// which supersedes any request still in flight
+func (m *GenerateModel) startRequest() context.Context {
+	m.endRequest()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRequest = cancel
+	return ctx
+}
+
+// endRequest cancels the in-flight request (a no-op if it already finished),
+// and moves on the request ID so its remaining messages are ignored
+func (m *GenerateModel) endRequest() {
+	if m.cancelRequest != nil {
+		m.cancelRequest()
+		m.cancelRequest = nil
+	}
+	m.requestID++
+}
+
+func (m *GenerateModel) generate() tea.Cmd {
+	ctx := m.startRequest()
+	m.command = ""
+	if m.without != nil {
+		return withoutCommand(ctx, m.requestID, m.agent, m.prompt, m.without)
+	}
+	if m.correction != nil {
+		return correctCommand(ctx, m.requestID, m.agent, *m.correction)
+	}
+	return generateCommand(ctx, m.requestID, m.agent, m.prompt)
+}
+
 func (m GenerateModel) viewInput() string {
-	hint := lipgloss.NewStyle().Faint(true).Render("enter submit")
+	hintText := "enter submit"
+	if len(m.pastPrompts) > 0 {
+		hintText += "  ↑↓ past prompts  ctrl+r search"
+	}
+	hint := lipgloss.NewStyle().Faint(true).Render(hintText)
 	return m.commandInput.View() + "\n\n" + hint
 }
 
 func (m GenerateModel) viewGenerating() string {
-	return components.RenderSpinnerWithLabel(m.spinner.View(), "Generating")
+	var sections []string
+
+	sections = append(sections, components.RenderPrompt(m.prompt))
+	// as it will be once done, without any markdown around it
+	if command := shell.Normalize(m.command); command != "" {
+		sections = append(sections, components.RenderCommand(command))
+	}
+	label := "Generating"
+	switch {
+	case m.without != nil:
+		label = "Regenerating"
+	case m.correction != nil:
+		label = "Correcting"
+	}
+	sections = append(sections, components.RenderSpinnerWithLabel(m.spinner.View(), label))
+	sections = append(sections, m.help.ShortHelpView([]key.Binding{m.keys.Cancel}))
+
+	return strings.Join(sections, "\n\n")
 }
 
 func (m GenerateModel) viewConfirm() string {
@@ -269,14 +675,32 @@ func (m GenerateModel) viewConfirm() string {
 
 	sections = append(sections, components.RenderPrompt(m.prompt))
 	sections = append(sections, components.RenderCommand(m.command))
+	if m.dir != "" {
+		sections = append(sections, components.FaintStyle.Render("runs in "+m.dir))
+	}
 
 	if m.explanation != "" {
 		sections = append(sections, components.RenderExplanation(m.explanation, 78))
 	}
 
+	if risk := m.viewRisk(); risk != "" {
+		sections = append(sections, risk)
+	}
+	if missing := m.viewMissing(); missing != "" {
+		sections = append(sections, missing)
+	}
+
+	// a denied command can still be edited, copied or explained, just not run
+	m.keys.Run.SetEnabled(!m.risk.Denied())
 	m.keys.Explain.SetEnabled(m.explanation == "")
+	m.keys.Install.SetEnabled(m.install != "")
+	m.keys.Without.SetEnabled(len(m.missing) > 0 && m.agent != nil)
 	sections = append(sections, m.help.View(m.keys))
 
+	if m.editError != nil {
+		sections = append(sections, components.RenderError(m.editError))
+	}
+
 	if m.showCopiedFeedbackMessage {
 		sections = append(sections, components.RenderCopiedFeedback())
 	}
@@ -289,7 +713,37 @@ func (m GenerateModel) viewExplaining() string {
 
 	sections = append(sections, components.RenderPrompt(m.prompt))
 	sections = append(sections, components.RenderCommand(m.command))
+	if explanation := strings.TrimSpace(m.explanation); explanation != "" {
+		sections = append(sections, components.RenderExplanation(explanation, 78))
+	}
 	sections = append(sections, components.RenderSpinnerWithLabel(m.spinner.View(), "Explaining"))
+	sections = append(sections, m.help.ShortHelpView([]key.Binding{m.keys.Cancel}))
+
+	return strings.Join(sections, "\n\n")
+}
+
+func (m GenerateModel) viewTeaching() string {
+	var sections []string
+
+	sections = append(sections, components.RenderPrompt(m.prompt))
+	if m.teachError != nil {
+		sections = append(sections, components.RenderError(m.teachError))
+	}
+	sections = append(sections, "Not sure about this one. What command should I look at?\n"+m.teachInput.View())
+	sections = append(sections, m.help.ShortHelpView([]key.Binding{m.keys.Teach, m.keys.Cancel}))
+
+	return strings.Join(sections, "\n\n")
+}
+
+func (m GenerateModel) viewLearning() string {
+	var sections []string
+
+	sections = append(sections, components.RenderPrompt(m.prompt))
+	if command := shell.Normalize(m.command); command != "" {
+		sections = append(sections, components.RenderCommand(command))
+	}
+	sections = append(sections, components.RenderSpinnerWithLabel(m.spinner.View(), "Learning "+m.tool))
+	sections = append(sections, m.help.ShortHelpView([]key.Binding{m.keys.Cancel}))
 
 	return strings.Join(sections, "\n\n")
 }
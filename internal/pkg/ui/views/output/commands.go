@@ -1,83 +1,51 @@
 package views
 
 import (
-	"bufio"
+	"io"
 	"os/exec"
-	"strings"
 	"syscall"
+	"time"
 
 	"github.com/atotto/clipboard"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-type lineData struct {
-	text           string
-	carriageReturn bool
-}
-
-type outputBatchMsg struct {
-	lines    []lineData
+type outputChunkMsg struct {
+	data     []byte
 	isStderr bool
-	done     bool // EOF reached — this is the final batch from this stream
+	done     bool // EOF reached — this is the final chunk from this stream
 }
 
 type commandDoneMsg struct {
 	exitCode int
 }
 
-type clipboardCopiedMsg struct{}
-
-const maxBatchLines = 5
+// DoneMsg is sent once the user is finished with the output,
+// leaving the next step to the parent model
+type DoneMsg struct {
+	Result OutputResult
+}
 
-// readNextChunk reads lines in a batch, returning as many as are immediately
-// available in the bufio.Reader's internal buffer (up to maxBatchLines).
-// The cap ensures large output renders progressively instead of in one frame.
-func readNextChunk(reader *bufio.Reader, isStderr bool) tea.Cmd {
+func (m OutputModel) done() tea.Cmd {
+	result := m.Result()
 	return func() tea.Msg {
-		var batch []lineData
-		for {
-			text, cr, eof := readOneLine(reader)
-			if eof {
-				if text != "" {
-					batch = append(batch, lineData{text: text, carriageReturn: cr})
-				}
-				return outputBatchMsg{lines: batch, isStderr: isStderr, done: true}
-			}
-			batch = append(batch, lineData{text: text, carriageReturn: cr})
-			if reader.Buffered() == 0 || len(batch) >= maxBatchLines {
-				break
-			}
-		}
-		return outputBatchMsg{lines: batch, isStderr: isStderr}
+		return DoneMsg{Result: result}
 	}
 }
 
-// readOneLine reads byte-by-byte until \r or \n, handling CR semantics.
-// Byte-by-byte reading is necessary to detect \r boundaries immediately —
-// buffered line reading would block until \n, freezing progress bars.
-func readOneLine(reader *bufio.Reader) (text string, carriageReturn bool, eof bool) {
-	var buf []byte
-	for {
-		b, err := reader.ReadByte()
-		if err != nil {
-			return processBackspaces(string(buf)), false, true
-		}
+type clipboardCopiedMsg struct{}
 
-		switch b {
-		case '\n':
-			return processBackspaces(string(buf)), false, false
-		case '\r':
-			if next, peekErr := reader.Peek(1); peekErr == nil && next[0] == '\n' {
-				reader.ReadByte()
-				return processBackspaces(string(buf)), false, false
-			}
-			if len(buf) == 0 {
-				continue
-			}
-			return processBackspaces(string(buf)), true, false
-		default:
-			buf = append(buf, b)
-		}
+// Small enough that large output renders progressively instead of in one frame
+const maxChunkBytes = 4096
+
+// readNextChunk reads whatever output is available, up to maxChunkBytes.
+// Escape sequences and runes split across chunks are reassembled by the terminal.
+func readNextChunk(reader io.Reader, isStderr bool) tea.Cmd {
+	return func() tea.Msg {
+		buf := make([]byte, maxChunkBytes)
+		n, err := reader.Read(buf)
+		// any error ends the stream, reading a PTY whose command exited fails with EIO rather than EOF
+		return outputChunkMsg{data: buf[:n], isStderr: isStderr, done: err != nil}
 	}
 }
 
@@ -86,10 +54,13 @@ func waitForExit(proc *exec.Cmd) tea.Cmd {
 		err := proc.Wait()
 		exitCode := 0
 		if err != nil {
+			exitCode = 1
 			if exitErr, ok := err.(*exec.ExitError); ok {
 				exitCode = exitErr.ExitCode()
-			} else {
-				exitCode = 1
+				// killed by a signal, which ExitCode reports as -1. Shells use 128+signal.
+				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+					exitCode = 128 + int(status.Signal())
+				}
 			}
 		}
 		return commandDoneMsg{exitCode: exitCode}
@@ -103,29 +74,22 @@ func copyToClipboard(text string) tea.Cmd {
 	}
 }
 
+// stopTimeoutMsg fires once the grace period after SIGTERM is over
+type stopTimeoutMsg struct{}
+
+func waitForGracePeriod(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return stopTimeoutMsg{}
+	})
+}
+
+// signalProcessGroup signals the command along with everything it started, since
+// the command runs in its own process group (pipe mode) or session (PTY mode).
 // ProcessState is nil until Wait() returns, so this guards against signaling
 // a process that has already exited (which would be a no-op on some OSes
 // but could signal a recycled PID on others).
-func killProcess(proc *exec.Cmd) {
+func signalProcessGroup(proc *exec.Cmd, sig syscall.Signal) {
 	if proc.Process != nil && proc.ProcessState == nil {
-		proc.Process.Signal(syscall.SIGTERM)
-	}
-}
-
-func processBackspaces(text string) string {
-	if !strings.ContainsRune(text, '\b') {
-		return text
-	}
-	runes := []rune(text)
-	var out []rune
-	for _, r := range runes {
-		if r == '\b' {
-			if len(out) > 0 {
-				out = out[:len(out)-1]
-			}
-		} else {
-			out = append(out, r)
-		}
+		syscall.Kill(-proc.Process.Pid, sig)
 	}
-	return string(out)
 }
@@ -10,12 +10,28 @@ type outputKeyMap struct {
 	DidntWork  key.Binding
 	CopyCmd    key.Binding
 	CopyOutput key.Binding
-	Cancel     key.Binding
+	// stops the running command, see newOutputKeyMap
+	Stop   key.Binding
+	Cancel key.Binding
+	Submit key.Binding
 }
 
 var _ help.KeyMap = (*outputKeyMap)(nil)
 
-func newOutputKeyMap() outputKeyMap {
+// newOutputKeyMap stops the command with esc, except with a PTY, where esc belongs to the
+// command (e.g. vim, less). ctrl+] is what telnet uses to get out of a session.
+func newOutputKeyMap(pty bool) outputKeyMap {
+	stop := key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("[esc]", "cancel"),
+	)
+	if pty {
+		stop = key.NewBinding(
+			key.WithKeys("ctrl+]"),
+			key.WithHelp("[ctrl+]]", "cancel"),
+		)
+	}
+
 	return outputKeyMap{
 		Done: key.NewBinding(
 			key.WithKeys("enter"),
@@ -33,15 +49,20 @@ func newOutputKeyMap() outputKeyMap {
 			key.WithKeys("o"),
 			key.WithHelp("[o]", "copy output"),
 		),
+		Stop: stop,
 		Cancel: key.NewBinding(
 			key.WithKeys("esc"),
 			key.WithHelp("[esc]", "cancel"),
 		),
+		Submit: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("[enter]", "submit"),
+		),
 	}
 }
 
 func (k outputKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Done, k.DidntWork, k.CopyCmd, k.CopyOutput, k.Cancel}
+	return []key.Binding{k.Done, k.DidntWork, k.CopyCmd, k.CopyOutput, k.Stop, k.Cancel}
 }
 
 func (k outputKeyMap) FullHelp() [][]key.Binding {
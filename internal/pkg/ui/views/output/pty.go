@@ -0,0 +1,84 @@
+package views
+
+import (
+	"os"
+	"os/exec"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/creack/pty"
+)
+
+// How long a cancelled command gets to exit after SIGTERM before it's sent SIGKILL
+const DEFAULT_KILL_GRACE_PERIOD = 3 * time.Second
+
+// RunOptions controls how NewOutputModel runs the command
+type RunOptions struct {
+	// run in a pseudo-terminal, so the command sees a TTY and can prompt for input.
+	// stdout and stderr arrive merged, as they would in a terminal.
+	PTY bool
+	// how long to wait after SIGTERM before sending SIGKILL, zero sends it right away
+	KillGracePeriod time.Duration
+	// where the command runs, the current directory when empty
+	Dir string
+}
+
+// startPTY starts proc as the session leader of a new pseudo-terminal,
+// returning the controlling side, which carries both its input and output.
+// The size is set up front, so the command doesn't lay out its first output for 80x24.
+func startPTY(proc *exec.Cmd, width, height int) (*os.File, error) {
+	if width <= 0 || height <= 0 {
+		return pty.Start(proc)
+	}
+	return pty.StartWithSize(proc, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+}
+
+func resizePTY(ptmx *os.File, width, height int) {
+	if ptmx == nil || width <= 0 || height <= 0 {
+		return
+	}
+	pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+}
+
+// keyBytes translates a key press back into what a terminal would send for it
+func keyBytes(msg tea.KeyMsg) []byte {
+	var b []byte
+
+	switch msg.Type {
+	case tea.KeyRunes:
+		b = []byte(string(msg.Runes))
+	case tea.KeySpace:
+		b = []byte(" ")
+	case tea.KeyUp:
+		b = []byte("\x1b[A")
+	case tea.KeyDown:
+		b = []byte("\x1b[B")
+	case tea.KeyRight:
+		b = []byte("\x1b[C")
+	case tea.KeyLeft:
+		b = []byte("\x1b[D")
+	case tea.KeyHome:
+		b = []byte("\x1b[H")
+	case tea.KeyEnd:
+		b = []byte("\x1b[F")
+	case tea.KeyDelete:
+		b = []byte("\x1b[3~")
+	case tea.KeyPgUp:
+		b = []byte("\x1b[5~")
+	case tea.KeyPgDown:
+		b = []byte("\x1b[6~")
+	case tea.KeyShiftTab:
+		b = []byte("\x1b[Z")
+	default:
+		// control keys (enter, tab, backspace, ctrl+<letter>) are their ASCII codes
+		if msg.Type >= 0 && msg.Type <= 31 || msg.Type == 127 {
+			b = []byte{byte(msg.Type)}
+		}
+	}
+
+	if msg.Alt && len(b) > 0 {
+		b = append([]byte{0x1b}, b...)
+	}
+
+	return b
+}
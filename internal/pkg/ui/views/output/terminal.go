@@ -0,0 +1,657 @@
+package views
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// terminal is a minimal emulator for command output. It understands what
+// progress UIs and colored output rely on (SGR colors, carriage returns,
+// erase-line, cursor movement), and ignores the rest.
+//
+// Unlike a real terminal, rows are logical lines that never wrap and never
+// scroll away, so the full transcript is kept for copying. Wrapping happens
+// at render time, see render.
+//
+// stdout and stderr are read concurrently, so which arrives first is up to the
+// scheduler. Each stream gets its own cursor and moves between its own rows, so
+// their lines interleave without one landing in the middle of the other's.
+type terminal struct {
+	rows []termRow
+	// first row of the visible screen, which absolute cursor positioning is relative to
+	top int
+	// size of the visible screen, 0 when unknown, see screenSize
+	width, height int
+
+	streams [2]streamState
+	// the stream being written, and its cursor, see streamState
+	stream            int
+	row, pending, col int
+}
+
+type termRow struct {
+	cells  []cell
+	stream int
+}
+
+type cell struct {
+	r rune
+	// zero-width runes drawn along with r, e.g. combining accents
+	marks  string
+	style  cellStyle
+	stderr bool
+}
+
+// A wide rune (CJK, emoji) takes two columns, its cell is followed by one holding this
+const wideContinuation rune = 0
+
+// cellStyle is the active SGR state, stored as the parameters that recreate it
+type cellStyle struct {
+	fg, bg string
+	attrs  uint8
+}
+
+const (
+	attrBold uint8 = 1 << iota
+	attrFaint
+	attrItalic
+	attrUnderline
+	attrBlink
+	attrReverse
+	attrStrike
+)
+
+// SGR parameters that set and reset each attr, in the order of the attr bits
+var attrCodes = []struct{ set, reset int }{
+	{1, 22}, {2, 22}, {3, 23}, {4, 24}, {5, 25}, {7, 27}, {9, 29},
+}
+
+type streamState struct {
+	// The cursor is pending rows below row, which are only added once something
+	// is written there. Otherwise the newline ending the output, or a newline
+	// racing the other stream, would leave a blank row behind.
+	// row is -1 until the stream writes its first row.
+	row, pending, col int
+	saved             [3]int
+	style             cellStyle
+	// an escape sequence or UTF-8 rune split across reads
+	partial []byte
+}
+
+const (
+	streamStdout = 0
+	streamStderr = 1
+)
+
+// Sequences longer than this are garbage rather than incomplete, so they're dropped
+const maxPendingBytes = 256
+
+// Until the size is known, cursor movement is bounded by a traditional 80x24 screen
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+func newTerminal() *terminal {
+	t := &terminal{}
+	for i := range t.streams {
+		t.streams[i].row = -1
+		t.streams[i].pending = 1
+		// restoring without saving first goes back to the start
+		t.streams[i].saved = [3]int{-1, 1, 0}
+	}
+	return t
+}
+
+func (t *terminal) setSize(width, height int) {
+	t.width, t.height = width, height
+}
+
+// screenSize returns the size cursor movement is clamped to, like a real terminal's cursor
+// stopping at the edges. Otherwise "ESC [ 99999999 H" would add millions of rows.
+func (t *terminal) screenSize() (width, height int) {
+	width, height = t.width, t.height
+	if width <= 0 {
+		width = defaultWidth
+	}
+	if height <= 0 {
+		height = defaultHeight
+	}
+	return width, height
+}
+
+// empty reports whether the command has printed anything yet
+func (t *terminal) empty() bool {
+	return len(t.rows) == 0
+}
+
+// Write feeds command output through the emulator
+func (t *terminal) Write(data []byte, isStderr bool) {
+	t.stream = streamStdout
+	if isStderr {
+		t.stream = streamStderr
+	}
+	state := &t.streams[t.stream]
+	t.row, t.pending, t.col = state.row, state.pending, state.col
+	defer func() {
+		state.row, state.pending, state.col = t.row, t.pending, t.col
+	}()
+
+	if len(state.partial) > 0 {
+		data = append(state.partial, data...)
+		state.partial = nil
+	}
+
+	for i := 0; i < len(data); {
+		n, complete := t.step(data[i:])
+		if !complete {
+			if len(data)-i <= maxPendingBytes {
+				state.partial = append([]byte(nil), data[i:]...)
+			}
+			return
+		}
+		i += n
+	}
+}
+
+// step consumes one rune, control character or escape sequence from the start of data,
+// returning how many bytes were used, or complete=false if data ends partway through
+func (t *terminal) step(data []byte) (n int, complete bool) {
+	b := data[0]
+
+	switch {
+	case b == 0x1b:
+		return t.escape(data)
+	case b == '\n':
+		// pipes send bare newlines meaning "next line", and a PTY sends \r\n, either way the column resets
+		t.down(1)
+		t.col = 0
+	case b == '\r':
+		t.col = 0
+	case b == '\b':
+		t.col = max(0, t.col-1)
+	case b == '\t':
+		next := (t.col/8 + 1) * 8
+		for t.col < next {
+			t.put(' ')
+		}
+	case b < 0x20 || b == 0x7f:
+		// bell and other control characters have nothing to show
+	default:
+		if !utf8.FullRune(data) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRune(data)
+		t.put(r)
+		return size, true
+	}
+
+	return 1, true
+}
+
+// escape handles a sequence starting with ESC
+func (t *terminal) escape(data []byte) (n int, complete bool) {
+	if len(data) < 2 {
+		return 0, false
+	}
+
+	state := &t.streams[t.stream]
+
+	switch data[1] {
+	case '[':
+		return t.csi(data)
+	case ']', 'P', '_', '^':
+		// OSC (titles, hyperlinks) and other strings end with BEL or ST, none of them are shown
+		for i := 2; i < len(data); i++ {
+			if data[i] == 0x07 {
+				return i + 1, true
+			}
+			if data[i] == 0x1b && i+1 < len(data) && data[i+1] == '\\' {
+				return i + 2, true
+			}
+		}
+		return 0, false
+	case '(', ')', '*', '+', '#':
+		// charset selection, followed by one more byte
+		if len(data) < 3 {
+			return 0, false
+		}
+		return 3, true
+	case '7':
+		state.saved = [3]int{t.row, t.pending, t.col}
+	case '8':
+		t.row, t.pending, t.col = state.saved[0], state.saved[1], state.saved[2]
+	case 'M':
+		// reverse index
+		t.up(1)
+	}
+
+	return 2, true
+}
+
+// csi handles "ESC [ params intermediates final"
+func (t *terminal) csi(data []byte) (n int, complete bool) {
+	end := -1
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return 0, false
+	}
+
+	params := string(data[2:end])
+	final := data[end]
+
+	// private sequences (e.g. "?25l" hiding the cursor) don't affect the output
+	if strings.HasPrefix(params, "?") || strings.HasPrefix(params, ">") || strings.HasPrefix(params, "=") {
+		return end + 1, true
+	}
+
+	args := parseParams(params)
+	arg := func(i, fallback int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return fallback
+	}
+
+	state := &t.streams[t.stream]
+	width, height := t.screenSize()
+
+	switch final {
+	case 'm':
+		state.style = state.style.apply(args)
+	case 'A':
+		t.up(min(arg(0, 1), height))
+	case 'B':
+		t.down(min(arg(0, 1), height))
+	case 'C':
+		// rows don't wrap, so the cursor can already be past the edge
+		t.col = max(t.col, min(t.col+min(arg(0, 1), width), width-1))
+	case 'D':
+		t.col = max(0, t.col-arg(0, 1))
+	case 'E':
+		t.down(min(arg(0, 1), height))
+		t.col = 0
+	case 'F':
+		t.up(min(arg(0, 1), height))
+		t.col = 0
+	case 'G':
+		t.col = min(arg(0, 1), width) - 1
+	case 'H', 'f':
+		t.moveTo(arg(0, 1)-1, arg(1, 1)-1)
+	case 'd':
+		t.moveTo(arg(0, 1)-1, t.col)
+	case 'K':
+		t.eraseLine(arg(0, 0))
+	case 'J':
+		t.eraseDisplay(arg(0, 0))
+	case 's':
+		state.saved = [3]int{t.row, t.pending, t.col}
+	case 'u':
+		t.row, t.pending, t.col = state.saved[0], state.saved[1], state.saved[2]
+	}
+
+	return end + 1, true
+}
+
+func parseParams(params string) []int {
+	if params == "" {
+		return nil
+	}
+
+	// colon separated sub-parameters (e.g. "38:2:255:0:0") are treated like semicolons
+	fields := strings.FieldsFunc(params, func(r rune) bool {
+		return r == ';' || r == ':'
+	})
+	args := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, _ := strconv.Atoi(field)
+		args = append(args, n)
+	}
+	if strings.HasSuffix(params, ";") || strings.HasPrefix(params, ";") {
+		// an empty parameter means 0 (reset), e.g. "\x1b[;1m"
+		args = append(args, 0)
+	}
+
+	return args
+}
+
+// down moves the cursor n of the stream's own rows down
+func (t *terminal) down(n int) {
+	for ; n > 0; n-- {
+		if t.pending > 0 {
+			t.pending++
+			continue
+		}
+
+		next := t.row + 1
+		for next < len(t.rows) && t.rows[next].stream != t.stream {
+			next++
+		}
+		if next == len(t.rows) {
+			t.pending++
+		} else {
+			t.row = next
+		}
+	}
+}
+
+// up moves the cursor n of the stream's own rows up, staying on the visible screen
+func (t *terminal) up(n int) {
+	for ; n > 0; n-- {
+		if t.pending > 1 || t.pending == 1 && t.row >= 0 {
+			t.pending--
+			continue
+		}
+
+		prev := t.row - 1
+		for prev >= t.top && t.rows[prev].stream != t.stream {
+			prev--
+		}
+		if prev < t.top {
+			return
+		}
+		t.row = prev
+	}
+}
+
+// moveTo puts the cursor at a row of the visible screen and a column, adding rows as needed
+func (t *terminal) moveTo(row, col int) {
+	width, height := t.screenSize()
+	t.row = t.top + max(0, min(row, height-1))
+	t.pending = 0
+	t.col = max(0, min(col, width-1))
+	for len(t.rows) <= t.row {
+		t.rows = append(t.rows, termRow{stream: t.stream})
+	}
+	t.scrollTo(t.row)
+}
+
+// materialize adds the rows the cursor has moved down to, so it can be written to
+func (t *terminal) materialize() {
+	for ; t.pending > 0; t.pending-- {
+		t.rows = append(t.rows, termRow{stream: t.stream})
+		t.row = len(t.rows) - 1
+	}
+	t.scrollTo(t.row)
+}
+
+// scrollTo moves the visible screen down to include row, like a terminal scrolling
+func (t *terminal) scrollTo(row int) {
+	if t.height > 0 && row-t.top >= t.height {
+		t.top = row - t.height + 1
+	}
+}
+
+func (t *terminal) put(r rune) {
+	width := runewidth.RuneWidth(r)
+	if width == 0 {
+		t.mark(r)
+		return
+	}
+	t.materialize()
+
+	cells := t.rows[t.row].cells
+	for len(cells) < t.col+width {
+		cells = append(cells, cell{r: ' '})
+	}
+	// overwriting half of a wide rune blanks the other half, like a terminal does
+	if cells[t.col].r == wideContinuation {
+		cells[t.col-1] = cell{r: ' '}
+	}
+	if end := t.col + width; end < len(cells) && cells[end].r == wideContinuation {
+		cells[end] = cell{r: ' '}
+	}
+
+	c := cell{
+		r:      r,
+		style:  t.streams[t.stream].style,
+		stderr: t.stream == streamStderr,
+	}
+	cells[t.col] = c
+	if width == 2 {
+		c.r = wideContinuation
+		cells[t.col+1] = c
+	}
+	t.rows[t.row].cells = cells
+
+	t.col += width
+}
+
+// mark adds a zero-width rune to the one before the cursor, or drops it when there's none
+func (t *terminal) mark(r rune) {
+	if t.pending > 0 {
+		return
+	}
+
+	cells := t.rows[t.row].cells
+	i := min(t.col, len(cells)) - 1
+	if i >= 0 && cells[i].r == wideContinuation {
+		i--
+	}
+	if i >= 0 {
+		cells[i].marks += string(r)
+	}
+}
+
+// eraseLine handles "ESC [ n K": 0 clears to the end of the line, 1 to the start, 2 all of it
+func (t *terminal) eraseLine(mode int) {
+	// nothing has been written where the cursor is yet
+	if t.pending > 0 {
+		return
+	}
+	cells := t.rows[t.row].cells
+
+	switch mode {
+	case 0:
+		if t.col < len(cells) {
+			t.rows[t.row].cells = cells[:t.col]
+		}
+	case 1:
+		for i := 0; i <= t.col && i < len(cells); i++ {
+			cells[i] = cell{r: ' '}
+		}
+		// the second half of a wide rune goes with the first
+		if next := t.col + 1; next < len(cells) && cells[next].r == wideContinuation {
+			cells[next] = cell{r: ' '}
+		}
+	case 2:
+		t.rows[t.row].cells = nil
+	}
+}
+
+// eraseDisplay handles "ESC [ n J". Rows are cleared rather than removed, so the
+// other stream's cursor stays put. Clearing the whole screen starts a fresh one below
+// the existing output instead of discarding it, like terminals keeping it in scrollback.
+func (t *terminal) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		if t.pending > 0 {
+			return
+		}
+		t.eraseLine(0)
+		for row := t.row + 1; row < len(t.rows); row++ {
+			if t.rows[row].stream == t.stream {
+				t.rows[row].cells = nil
+			}
+		}
+	case 1:
+		end := t.row
+		if t.pending > 0 {
+			end = t.row + 1
+		}
+		for row := max(0, t.top); row < end; row++ {
+			if t.rows[row].stream == t.stream {
+				t.rows[row].cells = nil
+			}
+		}
+		t.eraseLine(1)
+	case 2, 3:
+		t.top = len(t.rows)
+		t.row = len(t.rows) - 1
+		t.pending = 1
+	}
+}
+
+// trimBlankRows drops the empty rows at the end, e.g. from erased progress output
+func trimBlankRows(rows []termRow) []termRow {
+	for len(rows) > 0 && len(rows[len(rows)-1].cells) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+func (s cellStyle) apply(args []int) cellStyle {
+	if len(args) == 0 {
+		return cellStyle{}
+	}
+
+	for i := 0; i < len(args); i++ {
+		code := args[i]
+		switch {
+		case code == 0:
+			s = cellStyle{}
+		case code == 38 || code == 48:
+			// extended colors: 38;5;n (256 colors) or 38;2;r;g;b (truecolor)
+			var color string
+			if i+2 < len(args) && args[i+1] == 5 {
+				color = strconv.Itoa(code) + ";5;" + strconv.Itoa(args[i+2])
+				i += 2
+			} else if i+4 < len(args) && args[i+1] == 2 {
+				color = strconv.Itoa(code) + ";2;" + strconv.Itoa(args[i+2]) + ";" + strconv.Itoa(args[i+3]) + ";" + strconv.Itoa(args[i+4])
+				i += 4
+			} else {
+				// malformed, the rest can't be interpreted reliably
+				return s
+			}
+			if code == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+		case code == 39:
+			s.fg = ""
+		case code == 49:
+			s.bg = ""
+		case code >= 30 && code <= 37, code >= 90 && code <= 97:
+			s.fg = strconv.Itoa(code)
+		case code >= 40 && code <= 47, code >= 100 && code <= 107:
+			s.bg = strconv.Itoa(code)
+		default:
+			for bit, attr := range attrCodes {
+				if code == attr.set {
+					s.attrs |= 1 << bit
+				} else if code == attr.reset {
+					s.attrs &^= 1 << bit
+				}
+			}
+		}
+	}
+
+	return s
+}
+
+// sgr returns the escape sequence that switches to s from the default style
+func (s cellStyle) sgr() string {
+	var params []string
+	for bit, attr := range attrCodes {
+		if s.attrs&(1<<bit) != 0 {
+			params = append(params, strconv.Itoa(attr.set))
+		}
+	}
+	if s.fg != "" {
+		params = append(params, s.fg)
+	}
+	if s.bg != "" {
+		params = append(params, s.bg)
+	}
+
+	if len(params) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// stderr without a color of its own is shown in red, same as lipgloss.Color("9")
+const stderrColor = "91"
+
+// render returns the rows from start onwards, styled and hard-wrapped to width.
+// Output (tables, HTML, progress bars) rarely has convenient whitespace, so
+// word-wrapping would leave long "words" overflowing the viewport.
+func (t *terminal) render(start int, width int) []string {
+	var lines []string
+	for _, row := range trimBlankRows(t.rows[start:]) {
+		lines = append(lines, renderRow(row.cells, width)...)
+	}
+	return lines
+}
+
+func renderRow(row []cell, width int) []string {
+	var lines []string
+	var sb strings.Builder
+	lineWidth := 0
+	current := cellStyle{}
+
+	flush := func() {
+		if current != (cellStyle{}) {
+			sb.WriteString("\x1b[0m")
+		}
+		lines = append(lines, sb.String())
+		sb.Reset()
+		lineWidth = 0
+		current = cellStyle{}
+	}
+
+	for _, c := range row {
+		if c.r == wideContinuation {
+			continue
+		}
+		cw := runewidth.RuneWidth(c.r)
+		if width > 0 && lineWidth > 0 && lineWidth+cw > width {
+			flush()
+		}
+
+		style := c.style
+		if c.stderr && style.fg == "" {
+			style.fg = stderrColor
+		}
+		if style != current {
+			if current != (cellStyle{}) {
+				sb.WriteString("\x1b[0m")
+			}
+			sb.WriteString(style.sgr())
+			current = style
+		}
+
+		sb.WriteRune(c.r)
+		sb.WriteString(c.marks)
+		lineWidth += cw
+	}
+	flush()
+
+	return lines
+}
+
+// plainText returns the output without styling, for copying and the scrollback
+func (t *terminal) plainText() string {
+	var sb strings.Builder
+	for _, row := range trimBlankRows(t.rows) {
+		var line strings.Builder
+		for _, c := range row.cells {
+			if c.r != wideContinuation {
+				line.WriteRune(c.r)
+				line.WriteString(c.marks)
+			}
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,160 @@
+package views
+
+import (
+	"testing"
+)
+
+func TestTerminalWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{
+			name:   "plain lines",
+			writes: []string{"one\ntwo\n"},
+			want:   "one\ntwo\n",
+		},
+		{
+			name:   "carriage return overwrites progress",
+			writes: []string{"10%\r50%\r100%\n"},
+			want:   "100%\n",
+		},
+		{
+			name:   "erase line",
+			writes: []string{"downloading\r\x1b[Kdone\n"},
+			want:   "done\n",
+		},
+		{
+			name:   "sequence split across writes",
+			writes: []string{"a\x1b[", "31mb\x1b[0m\n"},
+			want:   "ab\n",
+		},
+		{
+			name:   "rune split across writes",
+			writes: []string{"caf\xc3", "\xa9\n"},
+			want:   "café\n",
+		},
+		{
+			name:   "save and restore cursor",
+			writes: []string{"one\x1b7\ntwo\x1b8!\n"},
+			want:   "one!\ntwo\n",
+		},
+		{
+			name:   "restore without save",
+			writes: []string{"\x1b8one\n"},
+			want:   "one\n",
+		},
+		{
+			name:   "restore without save after output",
+			writes: []string{"one\ntwo\x1b[u!"},
+			want:   "one\ntwo\n!\n",
+		},
+		{
+			name:   "cursor up rewrites a row",
+			writes: []string{"one\ntwo\n\x1b[2Aone done\n"},
+			want:   "one done\ntwo\n",
+		},
+		{
+			name:   "absolute position past the screen",
+			writes: []string{"\x1b[99999999;99999999Hx"},
+			want:   "\n\n\n\n\n\n\n\n\n" + "         x\n",
+		},
+		{
+			name:   "column past the screen",
+			writes: []string{"\x1b[99999999Gx\r\x1b[99999999Cy"},
+			want:   "         y\n",
+		},
+		{
+			name:   "wide runes take two columns",
+			writes: []string{"日本\r\x1b[4Cx"},
+			want:   "日本x\n",
+		},
+		{
+			name:   "overwriting half of a wide rune",
+			writes: []string{"日本\r\x1b[1Cx\x1b[1Cy"},
+			want:   " x y\n",
+		},
+		{
+			name:   "erasing half of a wide rune",
+			writes: []string{"日本x\r\x1b[2C\x1b[1K"},
+			want:   "    x\n",
+		},
+		{
+			name:   "combining marks stay with their rune",
+			writes: []string{"cafe\u0301!\n"},
+			want:   "cafe\u0301!\n",
+		},
+		{
+			name:   "combining mark after a wide rune",
+			writes: []string{"日\u0301x\n"},
+			want:   "日\u0301x\n",
+		},
+		{
+			name:   "combining mark with nothing before it",
+			writes: []string{"\u0301x\n"},
+			want:   "x\n",
+		},
+		{
+			name:   "cursor down past the screen",
+			writes: []string{"a\x1b[99999999Bb"},
+			want:   "a\n\n\n\n\n\n\n\n\n\n b\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			term := newTerminal()
+			term.setSize(10, 10)
+			for _, write := range test.writes {
+				term.Write([]byte(write), false)
+			}
+
+			if got := term.plainText(); got != test.want {
+				t.Errorf("plainText() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTerminalClampsWithoutSize(t *testing.T) {
+	term := newTerminal()
+	term.Write([]byte("\x1b[99999999;99999999Hx\x1b[99999999B\ry"), false)
+
+	if len(term.rows) > 2*defaultHeight {
+		t.Errorf("got %d rows, want at most %d", len(term.rows), 2*defaultHeight)
+	}
+	for _, row := range term.rows {
+		if len(row.cells) > defaultWidth {
+			t.Errorf("got a row of %d cells, want at most %d", len(row.cells), defaultWidth)
+		}
+	}
+}
+
+func TestTerminalRenderWideRunes(t *testing.T) {
+	term := newTerminal()
+	term.Write([]byte("日本語abc\n"), false)
+
+	want := []string{"日本", "語ab", "c"}
+	got := term.render(0, 4)
+	if len(got) != len(want) {
+		t.Fatalf("render() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("render() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestTerminalStreams(t *testing.T) {
+	term := newTerminal()
+	term.Write([]byte("out "), false)
+	term.Write([]byte("err\n"), true)
+	term.Write([]byte("put\n"), false)
+
+	want := "out put\nerr\n"
+	if got := term.plainText(); got != want {
+		t.Errorf("plainText() = %q, want %q", got, want)
+	}
+}
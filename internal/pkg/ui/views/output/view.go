@@ -1,29 +1,31 @@
 package views
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/azvaliev/cmd/internal/pkg/ui/components"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 // maxRenderedLines caps what the viewport renders, not what we store, for performance.
-// All output is kept in m.lines so "copy output" gives the complete result
+// All output is kept in m.terminal so "copy output" gives the complete result
 // even when the viewport display is truncated.
 const maxRenderedLines = 5000
 
 var (
-	stderrStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
 	ruleStyle        = components.FaintStyle
 	emptyOutputStyle = lipgloss.NewStyle().Faint(true).Italic(true)
 )
@@ -38,42 +40,52 @@ var emptyOutputPhrases = []string{
 type OutputResult struct {
 	ExitCode int
 	Output   string
+	// the user stopped the command before it finished
+	Cancelled bool
+	// DidntWork means the user asked for a corrected command, see ai.Correction
+	DidntWork bool
+	Feedback  string
 }
 
 type state int
 
 const (
 	stateRunning state = iota
+	// cancelled, waiting for the command to exit before SIGKILL
+	stateStopping
 	stateDone
+	// collecting what went wrong before asking for a corrected command
+	stateFeedback
 )
 
-type outputLine struct {
-	text     string
-	isStderr bool
-	fromCR   bool // true = written by \r, can be replaced by the next CR chunk
-}
-
 type OutputModel struct {
 	prompt  string
 	command string
 
-	state    state
-	proc     *exec.Cmd
-	exitCode int
-
-	stdoutReader *bufio.Reader
-	stderrReader *bufio.Reader
+	state     state
+	proc      *exec.Cmd
+	exitCode  int
+	didntWork bool
+	cancelled bool
+	// how long a cancelled command gets to exit after SIGTERM
+	killGracePeriod time.Duration
+	// nil unless running with RunOptions.PTY, key presses are forwarded here
+	pty *os.File
+
+	stdoutReader io.Reader
+	stderrReader io.Reader
 	stdoutDone   bool
 	stderrDone   bool
 
-	lines         []outputLine
+	terminal      *terminal
 	autoScroll    bool
 	viewportDirty bool
 	emptyPhrase   string
 
-	viewport viewport.Model
-	ready    bool
-	spinner  spinner.Model
+	viewport      viewport.Model
+	ready         bool
+	spinner       spinner.Model
+	feedbackInput textinput.Model
 
 	termWidth  int
 	termHeight int
@@ -83,75 +95,128 @@ type OutputModel struct {
 	showCopiedFeedbackMessage string
 }
 
-func NewOutputModel(prompt, command string) (OutputModel, error) {
+// NewOutputModel starts running command. windowSize is the size of the terminal
+// cmd runs in, zero when it isn't known yet.
+func NewOutputModel(prompt, command string, opts RunOptions, windowSize tea.WindowSizeMsg) (OutputModel, error) {
 	shell := os.Getenv("SHELL")
 	if shell == "" {
 		shell = "/bin/sh"
 	}
 
 	proc := exec.Command(shell, "-c", command)
+	proc.Dir = opts.Dir
+
+	var ptmx *os.File
+	var stdoutReader, stderrReader io.Reader
+	if opts.PTY {
+		var err error
+		width, height := viewportSize(windowSize.Width, windowSize.Height)
+		ptmx, err = startPTY(proc, width, height)
+		if err != nil {
+			return OutputModel{}, fmt.Errorf("start command: %w", err)
+		}
+		stdoutReader = ptmx
+	} else {
+		// own process group, so cancelling reaches pipelines and background jobs too.
+		// pty.Start already makes the command a session leader.
+		proc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	stdoutPipe, err := proc.StdoutPipe()
-	if err != nil {
-		return OutputModel{}, fmt.Errorf("stdout pipe: %w", err)
-	}
-	stderrPipe, err := proc.StderrPipe()
-	if err != nil {
-		return OutputModel{}, fmt.Errorf("stderr pipe: %w", err)
-	}
+		stdoutPipe, err := proc.StdoutPipe()
+		if err != nil {
+			return OutputModel{}, fmt.Errorf("stdout pipe: %w", err)
+		}
+		stderrPipe, err := proc.StderrPipe()
+		if err != nil {
+			return OutputModel{}, fmt.Errorf("stderr pipe: %w", err)
+		}
 
-	if err := proc.Start(); err != nil {
-		return OutputModel{}, fmt.Errorf("start command: %w", err)
+		if err := proc.Start(); err != nil {
+			return OutputModel{}, fmt.Errorf("start command: %w", err)
+		}
+		stdoutReader = stdoutPipe
+		stderrReader = stderrPipe
 	}
 
 	s := spinner.New()
 	s.Spinner = components.DotBounceSpinner
 
+	feedbackInput := textinput.New()
+	feedbackInput.Prompt = "> "
+	feedbackInput.Placeholder = "what went wrong? should I try a different command?"
+	feedbackInput.PromptStyle = lipgloss.NewStyle().Faint(true)
+	feedbackInput.PlaceholderStyle = lipgloss.NewStyle().Faint(true)
+
 	return OutputModel{
-		prompt:       prompt,
-		command:      command,
-		state:        stateRunning,
-		proc:         proc,
-		stdoutReader: bufio.NewReader(stdoutPipe),
-		stderrReader: bufio.NewReader(stderrPipe),
-		autoScroll:   true,
-		emptyPhrase:  emptyOutputPhrases[rand.Intn(len(emptyOutputPhrases))],
-		spinner:      s,
-		help:         components.NewHelp(),
-		keys:         newOutputKeyMap(),
+		prompt:          prompt,
+		command:         command,
+		state:           stateRunning,
+		proc:            proc,
+		killGracePeriod: opts.KillGracePeriod,
+		pty:             ptmx,
+		stdoutReader:    stdoutReader,
+		stderrReader:    stderrReader,
+		// everything arrives on the PTY
+		stderrDone:    opts.PTY,
+		terminal:      newTerminal(),
+		autoScroll:    true,
+		emptyPhrase:   emptyOutputPhrases[rand.Intn(len(emptyOutputPhrases))],
+		spinner:       s,
+		feedbackInput: feedbackInput,
+		help:          components.NewHelp(),
+		keys:          newOutputKeyMap(opts.PTY),
 	}, nil
 }
 
 func (m OutputModel) Result() OutputResult {
-	var sb strings.Builder
-	for _, line := range m.lines {
-		sb.WriteString(line.text)
-		sb.WriteByte('\n')
-	}
 	return OutputResult{
-		ExitCode: m.exitCode,
-		Output:   sb.String(),
+		ExitCode:  m.exitCode,
+		Output:    m.terminal.plainText(),
+		Cancelled: m.cancelled,
+		DidntWork: m.didntWork,
+		Feedback:  strings.TrimSpace(m.feedbackInput.Value()),
 	}
 }
 
+func (m OutputModel) Prompt() string {
+	return m.prompt
+}
+
+func (m OutputModel) Command() string {
+	return m.command
+}
+
+// Dispose kills whatever is left of the command, there's no waiting out a grace period on exit
 func (m *OutputModel) Dispose() {
-	killProcess(m.proc)
+	signalProcessGroup(m.proc, syscall.SIGKILL)
+	if m.pty != nil {
+		m.pty.Close()
+	}
 }
 
 func (m OutputModel) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		m.spinner.Tick,
 		readNextChunk(m.stdoutReader, false),
-		readNextChunk(m.stderrReader, true),
-	)
+	}
+	if m.stderrReader != nil {
+		cmds = append(cmds, readNextChunk(m.stderrReader, true))
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m OutputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if msg.Type == tea.KeyCtrlC {
-			killProcess(m.proc)
-			return m, tea.Quit
+			// like in a terminal, the command decides what an interrupt means
+			if m.pty != nil && m.state == stateRunning {
+				m.pty.Write(keyBytes(msg))
+				return m, nil
+			}
+			if m.state == stateRunning || m.state == stateStopping {
+				return m.stop()
+			}
+			return m, m.done()
 		}
 
 	case tea.WindowSizeMsg:
@@ -159,14 +224,13 @@ func (m OutputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.termHeight = msg.Height
 		m.resizeViewport()
 		m.syncViewportContent()
+		resizePTY(m.pty, m.viewport.Width, m.viewport.Height)
 		return m, nil
 
-	case outputBatchMsg:
-		for _, line := range msg.lines {
-			m.appendOrReplaceLine(line.text, msg.isStderr, line.carriageReturn)
-		}
-		if len(msg.lines) > 0 {
-			m.syncViewportContent()
+	case outputChunkMsg:
+		if len(msg.data) > 0 {
+			m.terminal.Write(msg.data, msg.isStderr)
+			m.viewportDirty = true
 		}
 
 		if msg.done {
@@ -190,8 +254,14 @@ func (m OutputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, readNextChunk(m.stdoutReader, false)
 
 	case commandDoneMsg:
-		m.state = stateDone
 		m.exitCode = msg.exitCode
+		if m.pty != nil {
+			m.pty.Close()
+		}
+		if m.state == stateStopping {
+			return m, m.done()
+		}
+		m.state = stateDone
 		// Clear dirty flag before the final sync so a stale spinner tick
 		// doesn't trigger a redundant rebuild after we've already rendered.
 		m.viewportDirty = false
@@ -199,15 +269,24 @@ func (m OutputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.syncViewportContent()
 		return m, nil
 
+	case stopTimeoutMsg:
+		if m.state != stateStopping {
+			return m, nil
+		}
+		signalProcessGroup(m.proc, syscall.SIGKILL)
+		return m, m.done()
+
 	case clipboardCopiedMsg:
 		return m, nil
 	}
 
 	switch m.state {
-	case stateRunning:
+	case stateRunning, stateStopping:
 		return m.updateRunning(msg)
 	case stateDone:
 		return m.updateDone(msg)
+	case stateFeedback:
+		return m.updateFeedback(msg)
 	}
 
 	return m, nil
@@ -215,9 +294,13 @@ func (m OutputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m OutputModel) updateRunning(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if keyMsg, ok := msg.(tea.KeyMsg); ok {
-		if key.Matches(keyMsg, m.keys.Cancel) {
-			killProcess(m.proc)
-			return m, tea.Quit
+		if key.Matches(keyMsg, m.keys.Stop) {
+			return m.stop()
+		}
+		// everything else is typed into the command, e.g. answering a prompt
+		if m.pty != nil && m.state == stateRunning {
+			m.pty.Write(keyBytes(keyMsg))
+			return m, nil
 		}
 	}
 
@@ -240,6 +323,20 @@ func (m OutputModel) updateRunning(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// stop sends SIGTERM and gives the command the grace period to exit before it's killed.
+// Cancelling again while stopping, or without a grace period, kills it right away.
+func (m OutputModel) stop() (OutputModel, tea.Cmd) {
+	m.cancelled = true
+	if m.state == stateStopping || m.killGracePeriod == 0 {
+		signalProcessGroup(m.proc, syscall.SIGKILL)
+		return m, m.done()
+	}
+
+	m.state = stateStopping
+	signalProcessGroup(m.proc, syscall.SIGTERM)
+	return m, waitForGracePeriod(m.killGracePeriod)
+}
+
 func (m OutputModel) updateDone(msg tea.Msg) (tea.Model, tea.Cmd) {
 	keyMsg, ok := msg.(tea.KeyMsg)
 	if !ok {
@@ -252,11 +349,10 @@ func (m OutputModel) updateDone(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch {
 	case key.Matches(keyMsg, m.keys.Done):
-		return m, tea.Quit
+		return m, m.done()
 	case key.Matches(keyMsg, m.keys.DidntWork):
-		// TODO: correction flow - stay on this screen, gather user feedback, re-generate command
-		fmt.Fprintln(os.Stderr, "Command didn't work")
-		return m, tea.Quit
+		m.state = stateFeedback
+		return m, m.feedbackInput.Focus()
 	case key.Matches(keyMsg, m.keys.CopyCmd):
 		m.showCopiedFeedbackMessage = "Copied command to clipboard!"
 		return m, copyToClipboard(m.command)
@@ -264,7 +360,7 @@ func (m OutputModel) updateDone(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.showCopiedFeedbackMessage = "Copied output to clipboard!"
 		return m, copyToClipboard(m.Result().Output)
 	case key.Matches(keyMsg, m.keys.Cancel):
-		return m, tea.Quit
+		return m, m.done()
 	}
 
 	var cmd tea.Cmd
@@ -272,6 +368,34 @@ func (m OutputModel) updateDone(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+func (m OutputModel) updateFeedback(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(keyMsg, m.keys.Submit):
+			m.didntWork = true
+			return m, m.done()
+		case key.Matches(keyMsg, m.keys.Cancel):
+			m.state = stateDone
+			m.feedbackInput.Blur()
+			m.feedbackInput.Reset()
+			return m, nil
+		}
+
+		var cmd tea.Cmd
+		m.feedbackInput, cmd = m.feedbackInput.Update(msg)
+		return m, cmd
+	}
+
+	// mouse wheel still scrolls the output while typing
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+	m.feedbackInput, cmd = m.feedbackInput.Update(msg)
+	cmds = append(cmds, cmd)
+	m.viewport, cmd = m.viewport.Update(msg)
+	cmds = append(cmds, cmd)
+	return m, tea.Batch(cmds...)
+}
+
 func (m OutputModel) View() string {
 	if !m.ready {
 		return components.ViewStyle.Render("Initializing...")
@@ -287,6 +411,11 @@ func (m OutputModel) View() string {
 	m.keys.DidntWork.SetEnabled(m.state == stateDone)
 	m.keys.CopyCmd.SetEnabled(m.state == stateDone)
 	m.keys.CopyOutput.SetEnabled(m.state == stateDone)
+	m.keys.Stop.SetEnabled(m.state == stateRunning || m.state == stateStopping)
+	m.keys.Cancel.SetEnabled(m.state == stateDone || m.state == stateFeedback)
+	if m.state == stateStopping {
+		m.keys.Stop.SetHelp(m.keys.Stop.Help().Key, "kill")
+	}
 
 	copiedStyle := lipgloss.NewStyle().Italic(true)
 	feedbackLine := " "
@@ -298,6 +427,13 @@ func (m OutputModel) View() string {
 		m.help.View(m.keys),
 		feedbackLine,
 	}
+	// same height as the regular footer, so the viewport doesn't need resizing
+	if m.state == stateFeedback {
+		footerParts = []string{
+			m.feedbackInput.View(),
+			m.help.ShortHelpView([]key.Binding{m.keys.Submit, m.keys.Cancel}),
+		}
+	}
 
 	sections := []string{
 		header,
@@ -310,9 +446,12 @@ func (m OutputModel) View() string {
 
 func (m OutputModel) statusBarView() string {
 	var status string
-	if m.state == stateRunning {
+	switch m.state {
+	case stateRunning:
 		status = components.RenderStatusBox("Running " + m.spinner.View())
-	} else {
+	case stateStopping:
+		status = components.RenderStatusBox("Stopping… " + m.spinner.View())
+	default:
 		status = components.RenderExitCode(m.exitCode)
 	}
 	leadIn := ruleStyle.Render("─")
@@ -332,11 +471,8 @@ func (m *OutputModel) resizeViewport() {
 		return
 	}
 
-	vpHeight := m.termHeight - m.nonViewportHeight()
-	if vpHeight < 1 {
-		vpHeight = 1
-	}
-	vpWidth := m.termWidth - viewStyleHPadding()
+	vpWidth, vpHeight := viewportSize(m.termWidth, m.termHeight)
+	m.feedbackInput.Width = max(0, vpWidth-lipgloss.Width(m.feedbackInput.Prompt)-1)
 
 	if !m.ready {
 		m.viewport = viewport.New(vpWidth, vpHeight)
@@ -345,26 +481,7 @@ func (m *OutputModel) resizeViewport() {
 		m.viewport.Width = vpWidth
 		m.viewport.Height = vpHeight
 	}
-}
-
-// appendOrReplaceLine handles CR semantics: \r-terminated chunks replace the
-// previous \r chunk from the same stream (stdout/stderr) in-place.
-func (m *OutputModel) appendOrReplaceLine(text string, isStderr, carriageReturn bool) {
-	line := outputLine{text: text, isStderr: isStderr, fromCR: carriageReturn}
-
-	if len(m.lines) > 0 {
-		last := &m.lines[len(m.lines)-1]
-		// Only match lines from the same stream — stdout and stderr are read
-		// concurrently, so they interleave. Without this check, a stdout line
-		// arriving between stderr progress updates would break the CR chain.
-		if last.fromCR && last.isStderr == isStderr {
-			last.text = text
-			last.fromCR = carriageReturn
-			return
-		}
-	}
-
-	m.lines = append(m.lines, line)
+	m.terminal.setSize(vpWidth, vpHeight)
 }
 
 func (m *OutputModel) syncViewportContent() {
@@ -372,7 +489,7 @@ func (m *OutputModel) syncViewportContent() {
 		return
 	}
 
-	if len(m.lines) == 0 {
+	if m.terminal.empty() {
 		m.viewport.SetContent(emptyOutputStyle.Render(m.emptyPhrase))
 		return
 	}
@@ -382,39 +499,29 @@ func (m *OutputModel) syncViewportContent() {
 	// Only auto-scroll when they were already at the bottom (following live output).
 	m.autoScroll = m.viewport.AtBottom()
 
-	lines := m.lines
-	truncated := false
-	if len(lines) > maxRenderedLines {
-		lines = lines[len(lines)-maxRenderedLines:]
-		truncated = true
+	start := max(0, len(m.terminal.rows)-maxRenderedLines)
+	lines := m.terminal.render(start, m.viewport.Width)
+	if start > 0 {
+		lines = append([]string{"---- Output truncated, [o] to copy full output ----"}, lines...)
 	}
 
-	var sb strings.Builder
-	if truncated {
-		sb.WriteString("---- Output truncated, [o] to copy full output ----\n")
-	}
-	for i, line := range lines {
-		if i > 0 {
-			sb.WriteByte('\n')
-		}
-		// Wrap before styling so ANSI codes from stderrStyle don't interfere with width math.
-		text := hardWrap(line.text, m.viewport.Width)
-		if line.isStderr {
-			sb.WriteString(stderrStyle.Render(text))
-		} else {
-			sb.WriteString(text)
-		}
-	}
-
-	m.viewport.SetContent(sb.String())
+	m.viewport.SetContent(strings.Join(lines, "\n"))
 	if m.autoScroll {
 		m.viewport.GotoBottom()
 	}
 }
 
+// viewportSize returns the size of the viewport, and so of the PTY, in a terminal of the given size
+func viewportSize(termWidth, termHeight int) (width, height int) {
+	if termWidth == 0 || termHeight == 0 {
+		return 0, 0
+	}
+	return termWidth - viewStyleHPadding(), max(1, termHeight-nonViewportHeight())
+}
+
 // nonViewportHeight returns lines consumed by everything except the viewport:
 // ViewStyle vertical padding + header content + bars + separators + footer content.
-func (m OutputModel) nonViewportHeight() int {
+func nonViewportHeight() int {
 	vPad := components.ViewStyle.GetVerticalPadding()
 	// prompt(1) + blank(1) + command(1) + \n\n blank(1) = 4
 	header := 4
@@ -428,22 +535,3 @@ func (m OutputModel) nonViewportHeight() int {
 func viewStyleHPadding() int {
 	return components.ViewStyle.GetHorizontalPadding()
 }
-
-// hardWrap breaks text at exact character boundaries rather than word boundaries.
-// Command output (tables, HTML, progress bars) rarely has convenient whitespace,
-// so word-wrapping would leave long "words" overflowing the viewport.
-// Wrapping is applied at render time so the raw lines stay intact for clipboard copy.
-func hardWrap(text string, width int) string {
-	if width <= 0 || len(text) <= width {
-		return text
-	}
-	var sb strings.Builder
-	runes := []rune(text)
-	for i, r := range runes {
-		if i > 0 && i%width == 0 {
-			sb.WriteByte('\n')
-		}
-		sb.WriteRune(r)
-	}
-	return sb.String()
-}
@@ -0,0 +1,297 @@
+// Package workdir describes the directory cmd runs in, so the model can use the
+// actual file names instead of guessing them (e.g. "unzip the archive").
+package workdir
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Enough to list a typical project directory, without crowding out the rest of the prompt
+const DEFAULT_MAX_CHARS = 1500
+
+const (
+	maxMatches   = 10
+	maxFileTypes = 8
+	// counting a huge directory's entries isn't worth the wait
+	maxCountedEntries = 1000
+	gitTimeout        = 2 * time.Second
+	// room kept for "- ... and N more"
+	moreLineChars = 20
+)
+
+// words that refer to files by kind rather than by name or extension
+var kindExtensions = map[string][]string{
+	"archive":  {".zip", ".tar", ".gz", ".tgz", ".bz2", ".xz", ".7z", ".rar"},
+	"image":    {".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".heic", ".bmp"},
+	"photo":    {".png", ".jpg", ".jpeg", ".heic"},
+	"video":    {".mp4", ".mov", ".mkv", ".webm", ".avi"},
+	"movie":    {".mp4", ".mov", ".mkv", ".avi"},
+	"audio":    {".mp3", ".wav", ".flac", ".ogg", ".m4a"},
+	"song":     {".mp3", ".flac", ".m4a"},
+	"document": {".pdf", ".doc", ".docx", ".odt", ".txt", ".md"},
+	"script":   {".sh", ".bash", ".zsh", ".py"},
+}
+
+type entry struct {
+	name string
+	dir  bool
+	size int64
+}
+
+// Describe summarizes dir's top-level entries for the model: the kinds of files it holds,
+// the entries that query seems to refer to, and as many of the rest as fit in maxChars.
+// Hidden and gitignored entries are left out, unless query refers to them.
+// It's empty when maxChars doesn't even leave room for the path.
+func Describe(ctx context.Context, dir, query string, maxChars int) (string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var entries []entry
+	for _, dirEntry := range dirEntries {
+		if dirEntry.Name() == ".git" {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{name: dirEntry.Name(), dir: dirEntry.IsDir(), size: info.Size()})
+	}
+
+	matches := matchEntries(query, entries)
+	entries = slices.DeleteFunc(entries, func(e entry) bool {
+		return strings.HasPrefix(e.name, ".")
+	})
+	entries = withoutIgnored(ctx, dir, entries)
+
+	header := "The working directory is " + dir
+	if len(entries) == 0 && len(matches) == 0 {
+		header += ", it's empty."
+	}
+	// every part is checked against maxChars, so a long path or list can't crowd out the query
+	if len(header) > maxChars {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header + "\n")
+
+	if types := fileTypes(entries); types != "" {
+		if line := fmt.Sprintf("File types: %s\n", types); sb.Len()+len(line) <= maxChars {
+			sb.WriteString(line)
+		}
+	}
+
+	writeEntries(&sb, "Entries the query may refer to:\n", dir, matches, maxChars)
+
+	// what's listed above doesn't need listing again
+	entries = slices.DeleteFunc(entries, func(e entry) bool {
+		return slices.ContainsFunc(matches, func(match entry) bool { return match.name == e.name })
+	})
+	heading := "Entries:\n"
+	if len(matches) > 0 {
+		heading = "Other entries:\n"
+	}
+	writeEntries(&sb, heading, dir, entries, maxChars)
+
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// writeEntries lists entries under heading, as many as fit in maxChars, then how many didn't fit
+func writeEntries(sb *strings.Builder, heading, dir string, entries []entry, maxChars int) {
+	// a heading without room for anything under it isn't worth writing
+	if len(entries) == 0 || sb.Len()+len(heading)+moreLineChars > maxChars {
+		return
+	}
+	sb.WriteString(heading)
+
+	for i, e := range entries {
+		line := describeEntry(dir, e)
+		// the last entry doesn't need room for the "more" line after it
+		reserve := moreLineChars
+		if i == len(entries)-1 {
+			reserve = 0
+		}
+		if sb.Len()+len(line)+reserve > maxChars {
+			if more := fmt.Sprintf("- ... and %d more\n", len(entries)-i); sb.Len()+len(more) <= maxChars {
+				sb.WriteString(more)
+			}
+			return
+		}
+		sb.WriteString(line)
+	}
+}
+
+func describeEntry(dir string, e entry) string {
+	if !e.dir {
+		return fmt.Sprintf("- %s (%s)\n", e.name, formatSize(e.size))
+	}
+
+	count := countEntries(filepath.Join(dir, e.name))
+	switch {
+	case count < 0:
+		return fmt.Sprintf("- %s/\n", e.name)
+	case count == 1:
+		return fmt.Sprintf("- %s/ (1 entry)\n", e.name)
+	case count >= maxCountedEntries:
+		return fmt.Sprintf("- %s/ (%d+ entries)\n", e.name, maxCountedEntries)
+	}
+	return fmt.Sprintf("- %s/ (%d entries)\n", e.name, count)
+}
+
+// countEntries returns how many entries dir has, up to maxCountedEntries, or -1 if it can't be read
+func countEntries(dir string) int {
+	file, err := os.Open(dir)
+	if err != nil {
+		return -1
+	}
+	defer file.Close()
+
+	names, _ := file.Readdirnames(maxCountedEntries)
+	return len(names)
+}
+
+// fileTypes counts files by extension, most common first, e.g. "12 .go, 3 .md"
+func fileTypes(entries []entry) string {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		if ext := strings.ToLower(filepath.Ext(e.name)); !e.dir && ext != "" {
+			counts[ext]++
+		}
+	}
+
+	exts := make([]string, 0, len(counts))
+	for ext := range counts {
+		exts = append(exts, ext)
+	}
+	slices.SortFunc(exts, func(a, b string) int {
+		return cmp.Or(counts[b]-counts[a], strings.Compare(a, b))
+	})
+
+	var types []string
+	for _, ext := range exts[:min(len(exts), maxFileTypes)] {
+		types = append(types, fmt.Sprintf("%d %s", counts[ext], ext))
+	}
+	return strings.Join(types, ", ")
+}
+
+// withoutIgnored drops the entries git ignores, when dir is in a repository
+func withoutIgnored(ctx context.Context, dir string, entries []entry) []entry {
+	if len(entries) == 0 {
+		return entries
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return entries
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+
+	proc := exec.CommandContext(ctx, "git", "-C", dir, "check-ignore", "--stdin")
+	proc.Stdin = strings.NewReader(strings.Join(names, "\n") + "\n")
+	// exits 1 when nothing is ignored, and 128 outside a repository, either way the output is all that matters
+	output, _ := proc.Output()
+
+	ignored := strings.Split(string(bytes.TrimSpace(output)), "\n")
+	return slices.DeleteFunc(entries, func(e entry) bool {
+		return slices.Contains(ignored, e.name)
+	})
+}
+
+// matchEntries finds the entries the query mentions: by name, by extension ("the log files"),
+// by kind ("the archive"), or by a name with a typo in it
+func matchEntries(query string, entries []entry) []entry {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '_' && r != '-'
+	})
+
+	var matches []entry
+	for _, e := range entries {
+		if len(matches) == maxMatches {
+			break
+		}
+		if slices.ContainsFunc(words, func(word string) bool { return mentions(word, e) }) {
+			matches = append(matches, e)
+		}
+	}
+	return matches
+}
+
+func mentions(word string, e entry) bool {
+	word = strings.Trim(word, ".-_")
+	// short words ("a", "to", "of") would match nearly everything
+	if len(word) < 3 {
+		return false
+	}
+
+	name := strings.ToLower(e.name)
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	singular := strings.TrimSuffix(word, "s")
+
+	switch {
+	case strings.Contains(name, word):
+		return true
+	case !e.dir && ext != "" && (word == ext || singular == ext):
+		return true
+	case !e.dir && slices.Contains(kindExtensions[singular], "."+ext):
+		return true
+	case len(word) >= 5 && withinOneEdit(word, stem):
+		return true
+	}
+	return false
+}
+
+// withinOneEdit reports whether a and b differ by at most one inserted, deleted or changed rune
+func withinOneEdit(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(rb)-len(ra) > 1 {
+		return false
+	}
+
+	i := 0
+	for i < len(ra) && ra[i] == rb[i] {
+		i++
+	}
+	// skip the differing rune in b, and in a too when it's a change rather than an insertion
+	if len(ra) == len(rb) {
+		i++
+		return i > len(ra) || string(ra[i:]) == string(rb[i:])
+	}
+	return string(ra[i:]) == string(rb[i+1:])
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size) / unit
+	suffix := 0
+	for value >= unit && suffix < 3 {
+		value /= unit
+		suffix++
+	}
+	return fmt.Sprintf("%.1f %s", value, []string{"KB", "MB", "GB", "TB"}[suffix])
+}
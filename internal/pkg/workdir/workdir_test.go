@@ -0,0 +1,97 @@
+package workdir
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"backup.tar.gz", "notes.md", "main.go", "util.go", ".env"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		want        []string
+		wantMissing []string
+	}{
+		{
+			name:        "lists entries without hidden ones",
+			query:       "count lines of code",
+			want:        []string{"The working directory is " + dir, "File types: 2 .go, 1 .gz, 1 .md", "Entries:\n", "- main.go (4 B)", "- src/ (0 entries)"},
+			wantMissing: []string{".env", "may refer to"},
+		},
+		{
+			name:  "matches by kind",
+			query: "extract the archive",
+			want:  []string{"Entries the query may refer to:\n- backup.tar.gz (4 B)", "Other entries:\n"},
+		},
+		{
+			name:  "matches hidden entries by name",
+			query: "show the .env file",
+			want:  []string{"Entries the query may refer to:\n- .env (4 B)"},
+		},
+		{
+			name:  "matches a misspelled name",
+			query: "open the nootes file",
+			want:  []string{"Entries the query may refer to:\n- notes.md (4 B)"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Describe(context.Background(), dir, test.query, DEFAULT_MAX_CHARS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Describe() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, missing := range test.wantMissing {
+				if strings.Contains(got, missing) {
+					t.Errorf("Describe() = %q, want it to leave out %q", got, missing)
+				}
+			}
+		})
+	}
+}
+
+func TestDescribeStaysWithinMaxChars(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 50 {
+		name := filepath.Join(dir, fmt.Sprintf("report-%02d.csv", i))
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	header := len("The working directory is " + dir)
+	for _, maxChars := range []int{1, header - 1, header, header + 10, header + 40, header + 100, 500, DEFAULT_MAX_CHARS} {
+		t.Run(fmt.Sprint(maxChars), func(t *testing.T) {
+			// every entry matches the query, so the matches alone overflow a small budget
+			got, err := Describe(context.Background(), dir, "merge the reports", maxChars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) > maxChars {
+				t.Errorf("Describe() is %d chars, want at most %d:\n%s", len(got), maxChars, got)
+			}
+			if maxChars < header && got != "" {
+				t.Errorf("Describe() = %q, want empty when the path doesn't fit", got)
+			}
+		})
+	}
+}
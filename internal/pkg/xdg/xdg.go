@@ -0,0 +1,69 @@
+// Package xdg resolves per-user directories following the XDG Base Directory spec,
+// falling back to the spec's defaults under $HOME when the variables are unset.
+package xdg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const APP_NAME = "cmd"
+
+// ConfigDir is where user-edited configuration lives, e.g. ~/.config/cmd
+func ConfigDir() string {
+	return appDir("XDG_CONFIG_HOME", ".config")
+}
+
+// DataDir is where state we create and the user doesn't edit lives, e.g. ~/.local/share/cmd
+func DataDir() string {
+	return appDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// CacheDir is for anything that can be recreated when deleted, e.g. ~/.cache/cmd
+func CacheDir() string {
+	return appDir("XDG_CACHE_HOME", ".cache")
+}
+
+// RuntimeDir holds sockets, lock files and other state that shouldn't outlive a login session.
+// Falls back to a per-user directory under the system temp dir when $XDG_RUNTIME_DIR is unset (e.g. MacOS).
+func RuntimeDir() string {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" || !filepath.IsAbs(base) {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", APP_NAME, os.Getuid()))
+	}
+
+	return filepath.Join(base, APP_NAME)
+}
+
+func appDir(envVar string, homeFallback string) string {
+	base := os.Getenv(envVar)
+	// the spec says relative paths are invalid and should be ignored
+	if base == "" || !filepath.IsAbs(base) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		base = filepath.Join(home, homeFallback)
+	}
+
+	return filepath.Join(base, APP_NAME)
+}
+
+// ExpandHome replaces a leading ~ with the user's home directory
+func ExpandHome(path string) string {
+	if path != "~" && !hasHomePrefix(path) {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}
+
+func hasHomePrefix(path string) bool {
+	return len(path) > 1 && path[0] == '~' && path[1] == '/'
+}